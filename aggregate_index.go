@@ -0,0 +1,156 @@
+package dawg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Aggregates of values of all keys below a unit.
+type AggregateUnit struct {
+	Sum int64
+	Max int64
+	Min int64
+}
+
+const aggregateUnitSize = 24
+
+func (unit *AggregateUnit) clear() {
+	unit.Sum = 0
+	unit.Max = math.MinInt64
+	unit.Min = math.MaxInt64
+}
+
+// Adds a value of a key.
+func (unit *AggregateUnit) add(value int64) {
+	unit.Sum += value
+	if value > unit.Max {
+		unit.Max = value
+	}
+	if value < unit.Min {
+		unit.Min = value
+	}
+}
+
+// Adds values of keys below another unit.
+func (unit *AggregateUnit) merge(other *AggregateUnit) {
+	unit.Sum += other.Sum
+	if other.Max > unit.Max {
+		unit.Max = other.Max
+	}
+	if other.Min < unit.Min {
+		unit.Min = other.Min
+	}
+}
+
+// An optional companion of Index which stores sums, maximums and minimums of
+// values in subtrees.
+type AggregateIndex struct {
+	units []AggregateUnit
+}
+
+func NewAggregateIndex() *AggregateIndex {
+	return &AggregateIndex{}
+}
+
+func (ai *AggregateIndex) Size() sizeType {
+	return len(ai.units)
+}
+
+func (ai *AggregateIndex) TotalSize() sizeType {
+	return aggregateUnitSize * len(ai.units)
+}
+
+func (ai *AggregateIndex) FileSize() sizeType {
+	return 4 + ai.TotalSize()
+}
+
+// The root index
+func (ai *AggregateIndex) Root() baseType {
+	return 0
+}
+
+func (ai *AggregateIndex) Sum(i baseType) int64 {
+	return ai.units[i].Sum
+}
+
+func (ai *AggregateIndex) Max(i baseType) int64 {
+	return ai.units[i].Max
+}
+
+func (ai *AggregateIndex) Min(i baseType) int64 {
+	return ai.units[i].Min
+}
+
+// Checks that aggregates agree with values of a dictionary. Returns the first
+// problem found.
+func (ai *AggregateIndex) Validate(dict *Dictionary) error {
+	units, err := dict.reachableUnits()
+	if err != nil {
+		return err
+	}
+	if len(ai.units) != dict.size {
+		return fmt.Errorf("aggregate index has %d units, dictionary has %d", len(ai.units), dict.size)
+	}
+
+	for _, i := range units {
+		var expected AggregateUnit
+		expected.clear()
+		if dict.HasValue(i) {
+			expected.add(dict.Value64(i))
+		}
+		var offset baseType = i ^ dict.offset(i)
+		for label := 1; label < numOfLabels; label++ {
+			var childIndex baseType = offset ^ baseType(label)
+			if dict.label(childIndex) == baseType(label) {
+				expected.merge(&ai.units[childIndex])
+			}
+		}
+		if ai.units[i] != expected {
+			return fmt.Errorf("aggregate unit %d: %+v instead of %+v", i, ai.units[i], expected)
+		}
+	}
+	return nil
+}
+
+func ReadAggregateIndex(r io.Reader) *AggregateIndex {
+	ai := NewAggregateIndex()
+	if !ai.Read(r) {
+		return nil
+	}
+	return ai
+}
+
+// Reads an aggregate index from an input stream.
+func (ai *AggregateIndex) Read(r io.Reader) bool {
+	var baseSize baseType
+	err := binary.Read(r, binary.LittleEndian, &baseSize)
+	if err != nil {
+		return false
+	}
+
+	var size sizeType = sizeType(baseSize)
+	ai.units = make([]AggregateUnit, size)
+	err = binary.Read(r, binary.LittleEndian, &ai.units)
+	if err != nil {
+		return false
+	}
+	return true
+}
+
+// Writes an aggregate index to an output stream.
+func (ai *AggregateIndex) Write(w io.Writer) bool {
+	var baseSize baseType = baseType(len(ai.units))
+	err := binary.Write(w, binary.LittleEndian, baseSize)
+	if err != nil {
+		return false
+	}
+
+	err = binary.Write(w, binary.LittleEndian, ai.units)
+	if err != nil {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,425 @@
+package dawg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Format of input records.
+type InputFormat ucharType
+
+const (
+	// Tab separated columns.
+	TSVFormat InputFormat = iota
+	// Comma separated columns with quoting as in RFC 4180.
+	CSVFormat
+	// JSON objects, usually one per line.
+	JSONLinesFormat
+	// Whole lines are keys, and all values are 0.
+	LinesFormat
+)
+
+func (format InputFormat) String() string {
+	switch format {
+	case CSVFormat:
+		return "csv"
+	case JSONLinesFormat:
+		return "jsonl"
+	case LinesFormat:
+		return "lines"
+	case TSVFormat:
+		return "tsv"
+	}
+	return fmt.Sprintf("InputFormat(%d)", format)
+}
+
+// Options of BuildFrom. The zero value reads tab separated keys and values.
+type BuildOptions struct {
+	Format InputFormat
+
+	// Columns of keys and values in TSV and CSV records, numbered from 1.
+	// Keys are in the first column and values are in the second one if
+	// columns are 0.
+	KeyColumn   int
+	ValueColumn int
+	// Skips the first record of TSV and CSV input.
+	Header bool
+
+	// Fields of keys and values in JSON objects, "key" and "value" if empty.
+	KeyField   string
+	ValueField string
+	// Ignores values, so that all of them are 0.
+	NoValues bool
+	// Gives 0 to TSV and CSV records without a value column, so that plain
+	// lists of keys can be read. Otherwise such records are errors.
+	OptionalValues bool
+
+	// Sorts records by keys before building. Otherwise keys must be sorted.
+	Sort bool
+	// Encodes keys in UTF-C instead of UTF-8.
+	Utfc bool
+	// What to do with keys which occur more than once.
+	DuplicatePolicy DuplicatePolicy
+	// Keeps values in a value table, which allows 64-bit and negative values.
+	// Values of such dictionaries must be read with Value64.
+	ValueTable bool
+	// Builds a dawg on several goroutines if greater than 1.
+	Workers int
+
+	// Builds a guide, or a ranked guide in a given order.
+	Guide       bool
+	RankedGuide bool
+	Order       RankOrder
+	// Builds an index, also with aggregates of values if requested. Requires
+	// a guide.
+	Index      bool
+	Aggregates bool
+
+	Observer BuildObserver
+}
+
+// Options for tab separated keys and values, which are the same as the zero
+// value.
+func NewBuildOptions() *BuildOptions {
+	return &BuildOptions{
+		Format:      TSVFormat,
+		KeyColumn:   1,
+		ValueColumn: 2,
+		KeyField:    "key",
+		ValueField:  "value",
+	}
+}
+
+// Gets a column numbered from 1, or a default one if the column is 0.
+// Returns an index of the column.
+func columnIndex(column int, defaultColumn int) int {
+	if column == 0 {
+		column = defaultColumn
+	}
+	return column - 1
+}
+
+// Gets a field, or a default one if the field is empty.
+func fieldName(field string, defaultField string) string {
+	if field == "" {
+		return defaultField
+	}
+	return field
+}
+
+// Result of BuildFrom.
+type DictionarySet struct {
+	Dawg        *Dawg
+	Dictionary  *Dictionary
+	Guide       *Guide
+	RankedGuide *RankedGuide
+	Index       *Index
+	Aggregates  *AggregateIndex
+
+	NumOfKeys        sizeType
+	NumOfUnusedUnits baseType
+}
+
+// Guide which is built, if any.
+func (set *DictionarySet) SomeGuide() SomeGuide {
+	if set.RankedGuide != nil {
+		return set.RankedGuide
+	} else if set.Guide != nil {
+		return set.Guide
+	}
+	return nil
+}
+
+// Writes a dictionary followed by a guide, an index and aggregates, whichever
+// are built.
+func (set *DictionarySet) Write(w io.Writer) bool {
+	if !set.Dictionary.Write(w) {
+		return false
+	}
+	if guide := set.SomeGuide(); guide != nil && !guide.Write(w) {
+		return false
+	}
+	if set.Index != nil && !set.Index.Write(w) {
+		return false
+	}
+	if set.Aggregates != nil && !set.Aggregates.Write(w) {
+		return false
+	}
+	return true
+}
+
+// Record of input.
+type inputRecord struct {
+	key   []ucharType
+	value int64
+}
+
+// Reads records one by one. Returns io.EOF after the last record.
+type recordReader func() (inputRecord, error)
+
+// Reads a line of any length without a line break.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err == io.EOF && len(line) != 0 {
+		err = nil
+	}
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	return line, err
+}
+
+func (options *BuildOptions) encodeKey(key string) []ucharType {
+	if options.Utfc {
+		// Records may be kept for sorting, so keys are not in a shared buffer.
+		return UtfcAppend(nil, key)
+	}
+	return []ucharType(key)
+}
+
+// Makes a record from columns of TSV or CSV input.
+func (options *BuildOptions) columnsRecord(columns []string) (inputRecord, error) {
+	var record inputRecord
+	var keyIndex int = columnIndex(options.KeyColumn, 1)
+	if keyIndex < 0 || keyIndex >= len(columns) {
+		return record, fmt.Errorf("no key column %d", keyIndex+1)
+	}
+	record.key = options.encodeKey(columns[keyIndex])
+	if options.NoValues {
+		return record, nil
+	}
+	var valueIndex int = columnIndex(options.ValueColumn, 2)
+	if valueIndex >= len(columns) && options.OptionalValues {
+		return record, nil
+	} else if valueIndex < 0 || valueIndex >= len(columns) {
+		return record, fmt.Errorf("no value column %d", valueIndex+1)
+	}
+
+	var err error
+	record.value, err = strconv.ParseInt(strings.TrimSpace(columns[valueIndex]), 10, 64)
+	return record, err
+}
+
+// Makes a record from a JSON object.
+func (options *BuildOptions) objectRecord(object map[string]interface{}) (inputRecord, error) {
+	var record inputRecord
+	var keyField string = fieldName(options.KeyField, "key")
+	key, ok := object[keyField].(string)
+	if !ok {
+		return record, fmt.Errorf("no string field %q", keyField)
+	}
+	record.key = options.encodeKey(key)
+	if options.NoValues {
+		return record, nil
+	}
+
+	var valueField string = fieldName(options.ValueField, "value")
+	value, ok := object[valueField].(json.Number)
+	if !ok {
+		return record, fmt.Errorf("no number field %q", valueField)
+	}
+	var err error
+	record.value, err = value.Int64()
+	return record, err
+}
+
+func (options *BuildOptions) newRecordReader(r io.Reader) recordReader {
+	var numOfRecords sizeType = 0
+	var wrap = func(record inputRecord, err error) (inputRecord, error) {
+		if err != nil && err != io.EOF {
+			err = fmt.Errorf("record %d: %w", numOfRecords, err)
+		}
+		return record, err
+	}
+
+	switch options.Format {
+	case CSVFormat:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.ReuseRecord = true
+		return func() (inputRecord, error) {
+			for {
+				columns, err := reader.Read()
+				if err != nil {
+					return wrap(inputRecord{}, err)
+				}
+				numOfRecords++
+				if numOfRecords == 1 && options.Header {
+					continue
+				}
+				return wrap(options.columnsRecord(columns))
+			}
+		}
+
+	case JSONLinesFormat:
+		decoder := json.NewDecoder(r)
+		decoder.UseNumber()
+		return func() (inputRecord, error) {
+			var object map[string]interface{}
+			if err := decoder.Decode(&object); err != nil {
+				return wrap(inputRecord{}, err)
+			}
+			numOfRecords++
+			return wrap(options.objectRecord(object))
+		}
+	}
+
+	reader := bufio.NewReader(r)
+	return func() (inputRecord, error) {
+		for {
+			line, err := readLine(reader)
+			if err != nil {
+				return wrap(inputRecord{}, err)
+			}
+			numOfRecords++
+			if len(line) == 0 || (numOfRecords == 1 && options.Header && options.Format == TSVFormat) {
+				continue
+			}
+			if options.Format == LinesFormat {
+				return inputRecord{key: options.encodeKey(line)}, nil
+			}
+			return wrap(options.columnsRecord(strings.Split(line, "\t")))
+		}
+	}
+}
+
+// Builds a dictionary with a guide and an index as requested from records of
+// a given format.
+func BuildFrom(r io.Reader, options *BuildOptions) (*DictionarySet, error) {
+	if options == nil {
+		options = NewBuildOptions()
+	}
+	if options.Index && !options.Guide && !options.RankedGuide {
+		return nil, fmt.Errorf("index requires a guide")
+	}
+	if options.Format > LinesFormat {
+		return nil, fmt.Errorf("unknown input format %d", options.Format)
+	}
+
+	var insert func(key []ucharType, value int64) error
+	var finish func(dawg *Dawg) bool
+	if options.Workers > 1 {
+		builder := NewParallelDawgBuilder(options.Workers)
+		builder.SetDuplicatePolicy(options.DuplicatePolicy)
+		builder.SetObserver(options.Observer)
+		if options.ValueTable {
+			builder.UseValueTable()
+		}
+		insert = builder.Insert
+		finish = builder.Finish
+	} else {
+		builder := NewDawgBuilder()
+		builder.SetDuplicatePolicy(options.DuplicatePolicy)
+		builder.SetObserver(options.Observer)
+		if options.ValueTable {
+			builder.UseValueTable()
+		}
+		insert = builder.Insert
+		finish = func(dawg *Dawg) bool {
+			builder.Finish(dawg)
+			return true
+		}
+	}
+
+	var numOfKeys sizeType = 0
+	var records []inputRecord
+	var read recordReader = options.newRecordReader(r)
+	for {
+		record, err := read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		numOfKeys++
+
+		if options.Sort {
+			records = append(records, record)
+		} else if err := insert(record.key, record.value); err != nil {
+			return nil, fmt.Errorf("key %q: %w", record.key, err)
+		}
+	}
+
+	if options.Sort {
+		// Keeps the order of duplicates for duplicate policies.
+		sort.SliceStable(records, func(i int, j int) bool {
+			return bytes.Compare(records[i].key, records[j].key) < 0
+		})
+		for _, record := range records {
+			if err := insert(record.key, record.value); err != nil {
+				return nil, fmt.Errorf("key %q: %w", record.key, err)
+			}
+		}
+		records = nil
+	}
+
+	var dawg *Dawg = NewDawg()
+	if !finish(dawg) {
+		return nil, fmt.Errorf("failed to build dawg")
+	}
+
+	set, err := BuildSet(dawg, options)
+	if err != nil {
+		return nil, err
+	}
+	set.NumOfKeys = numOfKeys
+	return set, nil
+}
+
+// Builds a dictionary with a guide and an index as requested from a dawg.
+// Options of input and of building a dawg are ignored.
+func BuildSet(dawg *Dawg, options *BuildOptions) (*DictionarySet, error) {
+	if options == nil {
+		options = NewBuildOptions()
+	}
+	if options.Index && !options.Guide && !options.RankedGuide {
+		return nil, fmt.Errorf("index requires a guide")
+	}
+
+	set := &DictionarySet{Dawg: dawg}
+
+	set.Dictionary = NewDictionary()
+	dictBuilder := NewDictionaryBuilder(set.Dawg, set.Dictionary)
+	dictBuilder.SetObserver(options.Observer)
+	if !dictBuilder.BuildDictionary() {
+		return nil, fmt.Errorf("failed to build dictionary")
+	}
+	set.NumOfUnusedUnits = dictBuilder.NumOfUnusedUnits()
+
+	if options.RankedGuide {
+		set.RankedGuide = NewRankedGuide()
+		guideBuilder := NewRankedGuideBuilder(set.Dawg, set.Dictionary, set.RankedGuide)
+		guideBuilder.SetObserver(options.Observer)
+		if !guideBuilder.BuildOrder(options.Order) {
+			return nil, fmt.Errorf("failed to build ranked guide")
+		}
+	} else if options.Guide {
+		set.Guide = NewGuide()
+		guideBuilder := NewGuideBuilder(set.Dawg, set.Dictionary, set.Guide)
+		guideBuilder.SetObserver(options.Observer)
+		if !guideBuilder.Build() {
+			return nil, fmt.Errorf("failed to build guide")
+		}
+	}
+
+	if options.Index {
+		set.Index = NewIndex()
+		indexBuilder := NewIndexBuilder(set.Dictionary, set.SomeGuide(), set.Index)
+		indexBuilder.SetObserver(options.Observer)
+		if options.Aggregates {
+			set.Aggregates = &AggregateIndex{}
+			indexBuilder.SetAggregates(set.Aggregates)
+		}
+		if !indexBuilder.Build() {
+			return nil, fmt.Errorf("failed to build index")
+		}
+	}
+
+	return set, nil
+}
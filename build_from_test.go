@@ -0,0 +1,108 @@
+package dawg
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBuildFrom(t *testing.T) {
+	var longKey string = strings.Repeat("x", 100000)
+	var tests = []struct {
+		input    string
+		options  *BuildOptions
+		records  sizeType
+		expected map[string]int64
+	}{
+		{
+			"apple\t5\nbanana\t-7\n\n" + longKey + "\t3\r\n",
+			&BuildOptions{ValueTable: true}, 3,
+			map[string]int64{"apple": 5, "banana": -7, longKey: 3},
+		},
+		{
+			"name,count\n\"b, c\",2\na,\"1\"\n",
+			&BuildOptions{Format: CSVFormat, Header: true, Sort: true}, 2,
+			map[string]int64{"a": 1, "b, c": 2},
+		},
+		{
+			"{\"k\": \"b\", \"v\": 9000000000}\n{\"k\": \"a\", \"v\": 1}\n{\"k\": \"b\", \"v\": 1}\n",
+			&BuildOptions{Format: JSONLinesFormat, KeyField: "k", ValueField: "v", Sort: true, DuplicatePolicy: DuplicateSum, ValueTable: true}, 3,
+			map[string]int64{"a": 1, "b": 9000000001},
+		},
+		{
+			"a\tb\nc\n",
+			&BuildOptions{Format: LinesFormat, Workers: 2}, 2,
+			map[string]int64{"a\tb": 0, "c": 0},
+		},
+		{
+			"cat\t1\nbat\t2\n",
+			&BuildOptions{Sort: true, Utfc: true}, 2,
+			map[string]int64{string(UtfcEncode("bat")): 2, string(UtfcEncode("cat")): 1},
+		},
+		{
+			"5\tapple\n7\tbanana\n",
+			&BuildOptions{KeyColumn: 2, ValueColumn: 1}, 2,
+			map[string]int64{"apple": 5, "banana": 7},
+		},
+		{
+			"{\"key\": \"a\", \"value\": 3}\n",
+			&BuildOptions{Format: JSONLinesFormat}, 1,
+			map[string]int64{"a": 3},
+		},
+		{
+			"a\nb\tx\n",
+			&BuildOptions{NoValues: true}, 2,
+			map[string]int64{"a": 0, "b": 0},
+		},
+		{
+			"a\nb\t2\n",
+			&BuildOptions{OptionalValues: true}, 2,
+			map[string]int64{"a": 0, "b": 2},
+		},
+	}
+
+	for i, test := range tests {
+		set, err := BuildFrom(strings.NewReader(test.input), test.options)
+		if err != nil {
+			t.Errorf("Test %d: %v", i, err)
+			continue
+		}
+		if set.NumOfKeys != test.records {
+			t.Errorf("Test %d: unexpected number of keys %d", i, set.NumOfKeys)
+		}
+		for key, value := range test.expected {
+			var result int64
+			if !set.Dictionary.FindStringValue64(key, &result) || result != value {
+				t.Errorf("Test %d: value of %.10q: expected %d, got %d", i, key, value, result)
+			}
+		}
+	}
+
+	set, err := BuildFrom(strings.NewReader("b\t2\na\t1\n"), &BuildOptions{RankedGuide: true, Index: true, Aggregates: true, Sort: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.RankedGuide == nil || set.Index == nil || set.Aggregates == nil {
+		t.Fatalf("Guide or index is not built")
+	}
+	var buf bytes.Buffer
+	if !set.Write(&buf) {
+		t.Errorf("Failed to write dictionary set")
+	}
+	if NewIndexer(set.Dictionary, set.RankedGuide, set.Index).TotalCount() != 2 {
+		t.Errorf("Wrong index")
+	}
+
+	var invalid = []string{"a\tx\n", "b\t1\na\t1\n", "a\n", "a\t-1\n"}
+	for _, input := range invalid {
+		if _, err := BuildFrom(strings.NewReader(input), nil); err == nil {
+			t.Errorf("Input %q is accepted", input)
+		}
+	}
+	if _, err := BuildFrom(strings.NewReader("a\t1\n"), &BuildOptions{Format: LinesFormat + 1}); err == nil {
+		t.Errorf("Unknown format is accepted")
+	}
+	if format := LinesFormat + 1; format.String() == TSVFormat.String() {
+		t.Errorf("Unknown format is reported as %q", format)
+	}
+}
@@ -0,0 +1,53 @@
+package dawg
+
+// Number of keys or units between progress events.
+const progressInterval = 1 << 16
+
+// Phase of building a dictionary.
+type BuildPhase ucharType
+
+const (
+	DawgPhase BuildPhase = iota
+	DictionaryPhase
+	GuidePhase
+	IndexPhase
+)
+
+func (phase BuildPhase) String() string {
+	switch phase {
+	case DictionaryPhase:
+		return "dictionary"
+	case GuidePhase:
+		return "guide"
+	case IndexPhase:
+		return "index"
+	}
+	return "dawg"
+}
+
+// Statistics of a phase. Counters which are not related to the phase are 0.
+type BuildStats struct {
+	Phase BuildPhase
+
+	// Dawg phase.
+	NumOfKeys              sizeType
+	NumOfStates            sizeType
+	NumOfMergedTransitions sizeType
+	NumOfHashTableResizes  sizeType
+	HashTableSize          sizeType
+
+	// Dictionary, guide and index phases.
+	NumOfUnits       sizeType
+	NumOfUnusedUnits sizeType
+}
+
+// Receives events from builders, e.g. to show progress of long builds.
+// Events are sent from the goroutine which calls a builder.
+type BuildObserver interface {
+	// Called when a phase starts.
+	OnPhaseStart(phase BuildPhase)
+	// Called periodically, and also when a hash table of a dawg is resized.
+	OnProgress(stats *BuildStats)
+	// Called when a phase ends with final statistics.
+	OnPhaseEnd(stats *BuildStats)
+}
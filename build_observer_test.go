@@ -0,0 +1,84 @@
+package dawg
+
+import (
+	"fmt"
+	"testing"
+)
+
+type testObserver struct {
+	events []string
+	stats  map[BuildPhase]*BuildStats
+}
+
+func (o *testObserver) OnPhaseStart(phase BuildPhase) {
+	o.events = append(o.events, "start "+phase.String())
+}
+func (o *testObserver) OnProgress(stats *BuildStats) {
+	if len(o.events) == 0 || o.events[len(o.events)-1] != "progress "+stats.Phase.String() {
+		o.events = append(o.events, "progress "+stats.Phase.String())
+	}
+}
+func (o *testObserver) OnPhaseEnd(stats *BuildStats) {
+	o.events = append(o.events, "end "+stats.Phase.String())
+	o.stats[stats.Phase] = stats
+}
+
+func TestBuildObserver(t *testing.T) {
+	observer := &testObserver{stats: map[BuildPhase]*BuildStats{}}
+
+	builder := NewDawgBuilderWithSize(4)
+	builder.SetObserver(observer)
+	for i := 0; i < 1000; i++ {
+		builder.InsertString(fmt.Sprintf("key%04d", i))
+	}
+	dawg := NewDawg()
+	builder.Finish(dawg)
+
+	dict := NewDictionary()
+	dictBuilder := NewDictionaryBuilder(dawg, dict)
+	dictBuilder.SetObserver(observer)
+	dictBuilder.BuildDictionary()
+
+	guide := &Guide{}
+	guideBuilder := NewGuideBuilder(dawg, dict, guide)
+	guideBuilder.SetObserver(observer)
+	guideBuilder.Build()
+
+	indexBuilder := NewIndexBuilder(dict, guide, &Index{})
+	indexBuilder.SetObserver(observer)
+	indexBuilder.Build()
+
+	var expected = []string{
+		"start dawg", "progress dawg", "end dawg",
+		"start dictionary", "end dictionary",
+		"start guide", "end guide",
+		"start index", "end index",
+	}
+	if fmt.Sprint(observer.events) != fmt.Sprint(expected) {
+		t.Errorf("Expected events %v, got %v", expected, observer.events)
+	}
+
+	var stats *BuildStats = observer.stats[DawgPhase]
+	if stats.NumOfKeys != 1000 || stats.NumOfStates != dawg.NumOfStates() || stats.NumOfHashTableResizes == 0 {
+		t.Errorf("Wrong dawg statistics: %+v", *stats)
+	}
+	if observer.stats[DictionaryPhase].NumOfUnits != dict.Size() || observer.stats[IndexPhase].NumOfUnits != dict.Size() {
+		t.Errorf("Wrong numbers of units")
+	}
+}
+
+func TestBuildObserverEmpty(t *testing.T) {
+	observer := &testObserver{stats: map[BuildPhase]*BuildStats{}}
+	builder := NewDawgBuilder()
+	builder.SetObserver(observer)
+	builder.Finish(NewDawg())
+
+	parallelBuilder := NewParallelDawgBuilder(2)
+	parallelBuilder.SetObserver(observer)
+	parallelBuilder.Finish(NewDawg())
+
+	var expected = []string{"start dawg", "end dawg", "start dawg", "end dawg"}
+	if fmt.Sprint(observer.events) != fmt.Sprint(expected) {
+		t.Errorf("Expected events %v, got %v", expected, observer.events)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deNULL/dawg"
+)
+
+// Creates a function answering queries for a single goroutine. It returns a
+// number of results, so that queries are not optimized away.
+type benchQueryFactory func() func(key string) int
+
+type benchResult struct {
+	numOfQueries int
+	elapsed      time.Duration
+	latencies    []time.Duration
+	allocs       uint64
+	numOfResults int
+}
+
+func (result *benchResult) percentile(p float64) time.Duration {
+	return result.latencies[int(p*float64(len(result.latencies)-1))]
+}
+
+// Calls fn on several goroutines and waits for them.
+func runWorkers(numOfWorkers int, fn func(w int)) {
+	var group sync.WaitGroup
+	for w := 0; w < numOfWorkers; w++ {
+		group.Add(1)
+		go func(w int) {
+			defer group.Done()
+			fn(w)
+		}(w)
+	}
+	group.Wait()
+}
+
+// Runs queries a given number of rounds on several goroutines. Only the whole
+// run is timed, since reading a clock takes about as long as a lookup. Then
+// up to numOfSamples queries spread over the run are timed one by one for
+// latencies, which include reading a clock.
+func runBench(queries []string, numOfWorkers int, rounds int, numOfSamples int, newQuery benchQueryFactory) *benchResult {
+	var total int = len(queries) * rounds
+	var fns []func(string) int = make([]func(string) int, numOfWorkers)
+	var latencies [][]time.Duration = make([][]time.Duration, numOfWorkers)
+	var numOfResults []int = make([]int, numOfWorkers)
+	if numOfSamples > total {
+		numOfSamples = total
+	}
+	for w := range fns {
+		fns[w] = newQuery()
+		latencies[w] = make([]time.Duration, 0, numOfSamples/numOfWorkers+1)
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	var start time.Time = time.Now()
+	runWorkers(numOfWorkers, func(w int) {
+		for i := w; i < total; i += numOfWorkers {
+			numOfResults[w] += fns[w](queries[i%len(queries)])
+		}
+	})
+	var elapsed time.Duration = time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	runWorkers(numOfWorkers, func(w int) {
+		for i := w; i < numOfSamples; i += numOfWorkers {
+			var query string = queries[i*(total/numOfSamples)%len(queries)]
+			var queryStart time.Time = time.Now()
+			fns[w](query)
+			latencies[w] = append(latencies[w], time.Since(queryStart))
+		}
+	})
+
+	result := &benchResult{numOfQueries: total, elapsed: elapsed, allocs: after.Mallocs - before.Mallocs}
+	for w := range latencies {
+		result.latencies = append(result.latencies, latencies[w]...)
+		result.numOfResults += numOfResults[w]
+	}
+	sort.Slice(result.latencies, func(i int, j int) bool {
+		return result.latencies[i] < result.latencies[j]
+	})
+	return result
+}
+
+// Makes factories of queries which are supported by a dictionary file.
+func benchQueries(f *dictFile, limit int) map[string]benchQueryFactory {
+	var dict *dawg.Dictionary = f.dict
+	var complete = func(newCompleter func() prefixCompleter) benchQueryFactory {
+		return func() func(string) int {
+			var c prefixCompleter = newCompleter()
+			return func(prefix string) int {
+				var index uint32 = dict.Root()
+				if !dict.FollowString(prefix, &index) {
+					return 0
+				}
+				c.StartString(index, prefix)
+				var n int = 0
+				for n < limit && c.Next() {
+					n++
+				}
+				return n
+			}
+		}
+	}
+
+	queries := map[string]benchQueryFactory{
+		"lookup": func() func(string) int {
+			return func(key string) int {
+				var value int64
+				if dict.FindStringValue64(key, &value) {
+					return 1
+				}
+				return 0
+			}
+		},
+		"prefixes": func() func(string) int {
+			return func(key string) int {
+				var index uint32 = dict.Root()
+				var n int = 0
+				for i := 0; i <= len(key); i++ {
+					if i != 0 && !dict.Follow(key[i-1], &index) {
+						break
+					}
+					if dict.HasValue(index) {
+						n++
+					}
+				}
+				return n
+			}
+		},
+	}
+	if f.guide != nil {
+		queries["complete"] = complete(func() prefixCompleter {
+			return dawg.NewCompleter(dict, f.guide)
+		})
+	}
+	if f.rankedGuide != nil {
+		queries["topk"] = func() func(string) int {
+			var c *dawg.RankedCompleter
+			if f.aggregates != nil {
+				c = dawg.NewRankedCompleterWithAggregates(dict, f.rankedGuide, f.aggregates)
+			} else {
+				c = dawg.NewRankedCompleter(dict, f.rankedGuide)
+			}
+			var results []dawg.Result
+			return func(prefix string) int {
+				results = c.TopK(prefix, limit, results)
+				return len(results)
+			}
+		}
+		if f.guide == nil {
+			queries["complete"] = complete(func() prefixCompleter {
+				return dawg.NewRankedCompleter(dict, f.rankedGuide)
+			})
+		}
+	}
+	return queries
+}
+
+func handleBench(args []string) {
+	var df dictFlags
+	var ops string
+	var numOfWorkers int
+	var rounds int
+	var limit int
+	var numOfSamples int
+	flags := newFlagSet("bench")
+	df.register(flags)
+	flags.StringVar(&ops, "ops", "lookup,prefixes,complete,topk", "comma separated queries to measure")
+	flags.IntVar(&numOfWorkers, "j", runtime.GOMAXPROCS(0), "number of goroutines")
+	flags.IntVar(&rounds, "rounds", 1, "number of passes over queries")
+	flags.IntVar(&limit, "n", 10, "number of completions of each prefix")
+	flags.IntVar(&numOfSamples, "samples", 10000, "number of queries timed one by one for latencies")
+	flags.Parse(args)
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return
+	}
+	if numOfWorkers <= 0 || rounds <= 0 || numOfSamples <= 0 {
+		log.Fatalf("error: numbers of goroutines, rounds and samples must be positive\n")
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	var start time.Time = time.Now()
+	f := readDict(flags.Arg(0), &df)
+	var loadTime time.Duration = time.Since(start)
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	if err := f.validate(); err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+
+	file := openInput(flags.Arg(1))
+	var queries []string
+	r := bufio.NewReader(file)
+	for {
+		line, err := readQuery(r)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			log.Fatal(err)
+		}
+		queries = append(queries, f.encodeKey(line))
+	}
+	file.Close()
+	if len(queries) == 0 {
+		log.Fatalf("error: no queries\n")
+	}
+
+	// Sizes of parts are exact, while the heap also holds garbage of reading
+	// and may even shrink.
+	var totalSize int = f.dict.TotalSize()
+	fmt.Printf("load time: %v\n", loadTime)
+	fmt.Printf("dictionary size: %d\n", f.dict.TotalSize())
+	if guide := f.someGuide(); guide != nil {
+		fmt.Printf("guide size: %d\n", guide.TotalSize())
+		totalSize += guide.TotalSize()
+	}
+	if f.index != nil {
+		fmt.Printf("index size: %d\n", f.index.TotalSize())
+		totalSize += f.index.TotalSize()
+	}
+	if f.aggregates != nil {
+		fmt.Printf("aggregates size: %d\n", f.aggregates.TotalSize())
+		totalSize += f.aggregates.TotalSize()
+	}
+	fmt.Printf("total size: %d\n", totalSize)
+	var heapSize int64 = int64(after.HeapAlloc) - int64(before.HeapAlloc)
+	if heapSize < 0 {
+		heapSize = 0
+	}
+	fmt.Printf("heap growth (estimate): %d\n", heapSize)
+	fmt.Printf("no. queries: %d\n", len(queries))
+	fmt.Printf("no. goroutines: %d\n", numOfWorkers)
+
+	var factories map[string]benchQueryFactory = benchQueries(f, limit)
+	for _, op := range strings.Split(ops, ",") {
+		newQuery, ok := factories[op]
+		if !ok {
+			fmt.Printf("%s: skipped\n", op)
+			continue
+		}
+		result := runBench(queries, numOfWorkers, rounds, numOfSamples, newQuery)
+		fmt.Printf("%s: %.0f queries/s, %.2f allocs/query, %.2f results/query, latency of %d samples: p50 %v, p90 %v, p99 %v, max %v\n",
+			op, float64(result.numOfQueries)/result.elapsed.Seconds(),
+			float64(result.allocs)/float64(result.numOfQueries), float64(result.numOfResults)/float64(result.numOfQueries),
+			len(result.latencies), result.percentile(0.5), result.percentile(0.9), result.percentile(0.99), result.percentile(1))
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/deNULL/dawg"
+)
+
+func TestRunBench(t *testing.T) {
+	var newQuery benchQueryFactory = func() func(string) int {
+		return func(key string) int {
+			return len(key)
+		}
+	}
+	result := runBench([]string{"a", "bb", "ccc"}, 2, 3, 4, newQuery)
+	if result.numOfQueries != 9 || result.numOfResults != 18 {
+		t.Errorf("Unexpected numbers of queries %d and results %d", result.numOfQueries, result.numOfResults)
+	}
+	if len(result.latencies) != 4 || result.percentile(0) > result.percentile(1) {
+		t.Errorf("Unexpected latencies %v", result.latencies)
+	}
+	if result = runBench([]string{"a"}, 1, 1, 100, newQuery); len(result.latencies) != 1 {
+		t.Errorf("Latencies are sampled more than queries are run")
+	}
+}
+
+func TestBenchQueriesTopK(t *testing.T) {
+	var lexicon strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&lexicon, "k%d\t%d\n", i, i%7)
+	}
+	options := &dawg.BuildOptions{Sort: true, RankedGuide: true, Aggregates: true}
+	set, err := dawg.BuildFrom(strings.NewReader(lexicon.String()), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := benchQueries(&dictFile{dict: set.Dictionary, rankedGuide: set.RankedGuide, aggregates: set.Aggregates}, 5)
+	complete, topk := queries["complete"](), queries["topk"]()
+	for _, prefix := range []string{"", "k", "k1", "k19", "k199", "x"} {
+		if expected, n := complete(prefix), topk(prefix); n != expected {
+			t.Errorf("Prefix %q: expected %d results, got %d", prefix, expected, n)
+		}
+	}
+}
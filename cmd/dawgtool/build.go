@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+
+	"github.com/deNULL/dawg"
+)
+
+// Prints progress of building.
+type progressPrinter struct{}
+
+func (p progressPrinter) OnPhaseStart(phase dawg.BuildPhase) {
+	fmt.Fprintf(os.Stderr, "%s: started\n", phase)
+}
+
+func (p progressPrinter) OnProgress(stats *dawg.BuildStats) {
+	if stats.Phase == dawg.DawgPhase {
+		fmt.Fprintf(os.Stderr, "%s: keys: %d, states: %d, merged transitions: %d, hash table size: %d\n",
+			stats.Phase, stats.NumOfKeys, stats.NumOfStates, stats.NumOfMergedTransitions, stats.HashTableSize)
+	} else if stats.Phase == dawg.DictionaryPhase {
+		fmt.Fprintf(os.Stderr, "%s: units: %d, unused units: %d\n", stats.Phase, stats.NumOfUnits, stats.NumOfUnusedUnits)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s: units: %d\n", stats.Phase, stats.NumOfUnits)
+	}
+}
+
+func (p progressPrinter) OnPhaseEnd(stats *dawg.BuildStats) {
+	p.OnProgress(stats)
+	fmt.Fprintf(os.Stderr, "%s: finished\n", stats.Phase)
+}
+
+func rankOrder(ascending bool) dawg.RankOrder {
+	if ascending {
+		return dawg.AscendingOrder
+	}
+	return dawg.DescendingOrder
+}
+
+func duplicatePolicy(name string) dawg.DuplicatePolicy {
+	switch name {
+	case "last":
+		return dawg.DuplicateLast
+	case "first":
+		return dawg.DuplicateFirst
+	case "error":
+		return dawg.DuplicateError
+	case "sum":
+		return dawg.DuplicateSum
+	case "max":
+		return dawg.DuplicateMax
+	case "min":
+		return dawg.DuplicateMin
+	}
+	log.Fatalf("error: unknown duplicate policy: %s\n", name)
+	return dawg.DuplicateLast
+}
+
+func inputFormat(name string) dawg.InputFormat {
+	switch name {
+	case "tsv":
+		return dawg.TSVFormat
+	case "csv":
+		return dawg.CSVFormat
+	case "jsonl":
+		return dawg.JSONLinesFormat
+	case "lines":
+		return dawg.LinesFormat
+	}
+	log.Fatalf("error: unknown input format: %s\n", name)
+	return dawg.LinesFormat
+}
+
+// Flags of reading a lexicon.
+type lexiconFlags struct {
+	format      string
+	header      bool
+	keyColumn   int
+	valueColumn int
+	keyField    string
+	valueField  string
+	noValues    bool
+	sort        bool
+	utfc        bool
+	duplicates  string
+	jobs        int
+	valueTable  bool
+	removedTab  bool
+}
+
+func (lf *lexiconFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&lf.format, "f", "tsv", "input format: tsv, csv, jsonl or lines")
+	flags.BoolVar(&lf.header, "H", false, "skip header of tsv or csv input")
+	flags.IntVar(&lf.keyColumn, "kc", 1, "column of keys in tsv or csv input, numbered from 1")
+	flags.IntVar(&lf.valueColumn, "vc", 2, "column of values in tsv or csv input, numbered from 1; records without it get 0")
+	flags.StringVar(&lf.keyField, "kf", "key", "field of keys in jsonl input")
+	flags.StringVar(&lf.valueField, "vf", "value", "field of values in jsonl input")
+	flags.BoolVar(&lf.noValues, "novalues", false, "ignore values of input, so that all of them are 0")
+	flags.BoolVar(&lf.sort, "s", false, "sort lexicon before building")
+	flags.BoolVar(&lf.utfc, "u", false, "use utf-c instead of utf-8 for encoding keys")
+	flags.StringVar(&lf.duplicates, "p", "last", "duplicate keys policy: last, first, error, sum, max or min")
+	flags.IntVar(&lf.jobs, "j", 1, "number of goroutines for building dawg (0 for all CPUs)")
+	flags.BoolVar(&lf.valueTable, "vt", false, "keep values in a value table to allow 64-bit and negative values")
+	flags.BoolVar(&lf.removedTab, "t", false, "removed, input is tab separated by default")
+}
+
+func (lf *lexiconFlags) options() *dawg.BuildOptions {
+	if lf.removedTab {
+		log.Fatalf("error: -t is removed, since input is tab separated by default; use -vt for a value table\n")
+	}
+	options := dawg.NewBuildOptions()
+	options.Format = inputFormat(lf.format)
+	options.Header = lf.header
+	if lf.keyColumn < 1 || lf.valueColumn < 1 {
+		log.Fatalf("error: columns are numbered from 1\n")
+	}
+	options.KeyColumn = lf.keyColumn
+	options.ValueColumn = lf.valueColumn
+	options.KeyField = lf.keyField
+	options.ValueField = lf.valueField
+	options.NoValues = lf.noValues
+	options.OptionalValues = true
+	options.Sort = lf.sort
+	options.Utfc = lf.utfc
+	options.DuplicatePolicy = duplicatePolicy(lf.duplicates)
+	options.Workers = lf.jobs
+	options.ValueTable = lf.valueTable
+	if lf.jobs == 0 {
+		options.Workers = runtime.GOMAXPROCS(0)
+	}
+	return options
+}
+
+// Flags of parts which are built after a dictionary.
+type partFlags struct {
+	guide      bool
+	ranked     bool
+	ascending  bool
+	index      bool
+	aggregates bool
+}
+
+func (pf *partFlags) register(flags *flag.FlagSet, prefix string) {
+	flags.BoolVar(&pf.guide, prefix+"g", false, "build guide")
+	flags.BoolVar(&pf.ranked, prefix+"r", false, "build ranked guide")
+	flags.BoolVar(&pf.ascending, prefix+"a", false, "rank smaller values first in ranked guide")
+	flags.BoolVar(&pf.index, prefix+"i", false, "build index (requires guide)")
+	flags.BoolVar(&pf.aggregates, prefix+"A", false, "build aggregates of values with index")
+}
+
+func (pf *partFlags) apply(options *dawg.BuildOptions) {
+	options.Guide = pf.guide
+	options.RankedGuide = pf.ranked
+	options.Order = rankOrder(pf.ascending)
+	options.Index = pf.index
+	options.Aggregates = pf.aggregates
+}
+
+// Writes a dictionary set to a file, or to stdout for "-".
+func writeSet(set *dawg.DictionarySet, path string) {
+	var file *os.File = os.Stdout
+	if path != "-" {
+		var err error
+		if file, err = os.Create(path); err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+	}
+
+	w := bufio.NewWriter(file)
+	if !set.Write(w) || w.Flush() != nil {
+		log.Fatalf("error: failed to write dictionary\n")
+	}
+}
+
+// Prints statistics of building, to stderr if stdout is taken by a
+// dictionary.
+func printBuildStats(w io.Writer, set *dawg.DictionarySet) {
+	d := set.Dawg
+	fmt.Fprintf(w, "no. keys: %d\n", set.NumOfKeys)
+	fmt.Fprintf(w, "no. duplicates: %d\n", d.NumOfDuplicates())
+	fmt.Fprintf(w, "no. states: %d\n", d.NumOfStates())
+	fmt.Fprintf(w, "no. transitions: %d\n", d.NumOfTransitions())
+	fmt.Fprintf(w, "no. merged states: %d\n", d.NumOfMergedStates())
+	fmt.Fprintf(w, "no. merging states: %d\n", d.NumOfMergingStates())
+	fmt.Fprintf(w, "no. merged transitions: %d\n", d.NumOfMergedTransitions())
+
+	dict := set.Dictionary
+	var unusedRatio float64 = 100.0 * float64(set.NumOfUnusedUnits) / float64(dict.Size())
+
+	fmt.Fprintf(w, "no. elements: %d\n", dict.Size())
+	fmt.Fprintf(w, "no. unused elements: %d (%.2f%%)\n", set.NumOfUnusedUnits, unusedRatio)
+	fmt.Fprintf(w, "dictionary size: %d\n", dict.TotalSize())
+	if dict.HasValueTable() {
+		fmt.Fprintf(w, "value table: yes\n")
+	}
+	if dict.IsWide() {
+		fmt.Fprintf(w, "wide units: yes\n")
+	}
+
+	if guide := set.SomeGuide(); guide != nil {
+		fmt.Fprintf(w, "no. units: %d\n", guide.Size())
+		fmt.Fprintf(w, "guide size: %d\n", guide.TotalSize())
+	}
+	if set.Index != nil {
+		fmt.Fprintf(w, "no. index units: %d\n", set.Index.Size())
+		fmt.Fprintf(w, "index size: %d\n", set.Index.TotalSize())
+	}
+	if set.Aggregates != nil {
+		fmt.Fprintf(w, "aggregates size: %d\n", set.Aggregates.TotalSize())
+	}
+}
+
+func handleBuild(args []string) {
+	var lf lexiconFlags
+	var pf partFlags
+	var verbose bool
+	flags := newFlagSet("build")
+	lf.register(flags)
+	pf.register(flags, "")
+	flags.BoolVar(&verbose, "v", false, "print progress of building")
+	flags.Parse(args)
+
+	var lexicon, dictionary string = "-", "-"
+	if flags.NArg() > 0 {
+		lexicon = flags.Arg(0)
+	}
+	if flags.NArg() > 1 {
+		dictionary = flags.Arg(1)
+	}
+
+	options := lf.options()
+	pf.apply(options)
+	if verbose {
+		options.Observer = progressPrinter{}
+	}
+
+	file := openInput(lexicon)
+	set, err := dawg.BuildFrom(file, options)
+	file.Close()
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+
+	writeSet(set, dictionary)
+	if dictionary == "-" {
+		printBuildStats(os.Stderr, set)
+	} else {
+		printBuildStats(os.Stdout, set)
+	}
+}
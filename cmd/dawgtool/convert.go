@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"sort"
+
+	"github.com/deNULL/dawg"
+)
+
+func handleConvert(args []string) {
+	var df dictFlags
+	var pf partFlags
+	var toUtfc, toUtf8 bool
+	flags := newFlagSet("convert")
+	df.register(flags)
+	pf.register(flags, "to-")
+	flags.BoolVar(&toUtfc, "to-utfc", false, "encode keys in utf-c")
+	flags.BoolVar(&toUtf8, "to-utf8", false, "encode keys in utf-8")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return
+	}
+	var output string = "-"
+	if flags.NArg() > 1 {
+		output = flags.Arg(1)
+	}
+
+	f := loadDict(flags.Arg(0), &df)
+
+	type record struct {
+		key   []byte
+		value int64
+	}
+	var records []record
+	f.dict.Walk(func(key []byte, value int64) bool {
+		if toUtfc && !f.utfc {
+			key = dawg.UtfcEncode(string(key))
+		} else if toUtf8 && f.utfc {
+			key = []byte(dawg.UtfcDecode(key))
+		}
+		records = append(records, record{append([]byte(nil), key...), value})
+		return true
+	})
+	// Encodings order keys differently.
+	sort.Slice(records, func(i int, j int) bool {
+		return bytes.Compare(records[i].key, records[j].key) < 0
+	})
+
+	builder := dawg.NewDawgBuilder()
+	if f.dict.HasValueTable() {
+		builder.UseValueTable()
+	}
+	for _, r := range records {
+		if err := builder.Insert(r.key, r.value); err != nil {
+			log.Fatalf("error: key %q: %v\n", r.key, err)
+		}
+	}
+	d := dawg.NewDawg()
+	builder.Finish(d)
+
+	options := dawg.NewBuildOptions()
+	pf.apply(options)
+	set, err := dawg.BuildSet(d, options)
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+	set.NumOfKeys = len(records)
+	writeSet(set, output)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/deNULL/dawg"
+)
+
+// Lists differences between dictionaries in key order. Both files are read
+// with the same flags, and plain guides are used to walk them faster, since
+// ranked guides do not list keys in order. It exits with status 1 if the
+// dictionaries differ, like diff.
+func handleDiff(args []string) {
+	var df dictFlags
+	flags := newFlagSet("diff")
+	df.register(flags)
+	output := registerOutput(flags)
+	flags.Parse(args)
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return
+	}
+
+	oldFile := loadDict(flags.Arg(0), &df)
+	newFile := loadDict(flags.Arg(1), &df)
+
+	p := newPrinter(*output)
+	it := dawg.NewDiffIterator(oldFile.dict, oldFile.guide, newFile.dict, newFile.guide)
+	for it.Next() {
+		entry := it.Entry()
+		var oldValue, newValue interface{} = entry.OldValue, entry.NewValue
+		if entry.Kind == dawg.DiffAdded {
+			oldValue = nil
+		} else if entry.Kind == dawg.DiffRemoved {
+			newValue = nil
+		}
+		p.print("change", entry.Kind.String(), "key", oldFile.decodeKey(entry.Key), "old", oldValue, "new", newValue)
+	}
+	p.flush()
+
+	fmt.Fprintf(os.Stderr, "added: %d, removed: %d, changed: %d, unchanged: %d\n",
+		it.NumOfAdded(), it.NumOfRemoved(), it.NumOfChanged(), it.NumOfUnchanged())
+	if it.NumOfAdded()+it.NumOfRemoved()+it.NumOfChanged() != 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/deNULL/dawg"
+)
+
+func handleStats(args []string) {
+	var df dictFlags
+	flags := newFlagSet("stats")
+	df.register(flags)
+	output := registerOutput(flags)
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return
+	}
+
+	f := loadDict(flags.Arg(0), &df)
+	var numOfKeys int = 0
+	f.dict.Walk(func(key []byte, value int64) bool {
+		numOfKeys++
+		return true
+	})
+
+	var fields []interface{} = []interface{}{
+		"keys", numOfKeys,
+		"units", f.dict.Size(),
+		"dictionary size", f.dict.TotalSize(),
+		"value table", f.dict.HasValueTable(),
+		"wide units", f.dict.IsWide(),
+	}
+	if f.rankedGuide != nil {
+		fields = append(fields, "guide", "ranked", "order", f.rankedGuide.Order().String())
+	} else if f.guide != nil {
+		fields = append(fields, "guide", "plain")
+	}
+	if guide := f.someGuide(); guide != nil {
+		fields = append(fields, "guide size", guide.TotalSize())
+	}
+	if f.index != nil {
+		fields = append(fields, "index size", f.index.TotalSize(), "indexed keys", f.index.TotalCount())
+	}
+	if f.aggregates != nil {
+		fields = append(fields, "aggregates size", f.aggregates.TotalSize())
+	}
+
+	p := newPrinter(*output)
+	if p.json {
+		p.print(fields...)
+	} else {
+		for i := 0; i < len(fields); i += 2 {
+			p.print("name", fields[i], "value", fields[i+1])
+		}
+	}
+	p.flush()
+}
+
+func handleVerify(args []string) {
+	var df dictFlags
+	var ascending bool
+	flags := newFlagSet("verify")
+	df.register(flags)
+	flags.BoolVar(&ascending, "a", false, "ranked guide ranks smaller values first")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return
+	}
+
+	f := loadDict(flags.Arg(0), &df)
+	if f.rankedGuide != nil && f.rankedGuide.Order() != rankOrder(ascending) && f.rankedGuide.Size() != 0 {
+		log.Fatalf("error: RankedGuide is built in %s order\n", f.rankedGuide.Order())
+	}
+	fmt.Printf("ok\n")
+}
+
+func handleDump(args []string) {
+	var df dictFlags
+	flags := newFlagSet("dump")
+	df.register(flags)
+	output := registerOutput(flags)
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return
+	}
+
+	f := loadDict(flags.Arg(0), &df)
+
+	p := newPrinter(*output)
+	dump(f, p)
+	p.flush()
+}
+
+// Prints all keys and values in the format of build input.
+func dump(f *dictFile, p *printer) {
+	f.dict.Walk(func(key []byte, value int64) bool {
+		p.print("key", f.decodeKey(key), "value", value)
+		return true
+	})
+}
+
+func handleDot(args []string) {
+	var df dictFlags
+	var build bool
+	var format string
+	var sortLexicon bool
+	var depth int
+	var prefix string
+	flags := newFlagSet("dot")
+	df.register(flags)
+	flags.BoolVar(&build, "b", false, "build dawg from a lexicon and draw it instead of a dictionary")
+	flags.StringVar(&format, "f", "tsv", "input format of lexicon: tsv, csv, jsonl or lines")
+	flags.BoolVar(&sortLexicon, "s", false, "sort lexicon before building")
+	flags.IntVar(&depth, "depth", 0, "maximum depth of states below prefix (0 for no limit)")
+	flags.StringVar(&prefix, "prefix", "", "draw only states under prefix")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return
+	}
+
+	options := &dawg.DOTOptions{MaxDepth: depth}
+	if !build {
+		// Only units which are drawn are checked, so that parts of damaged
+		// dictionaries can be drawn.
+		f := readDict(flags.Arg(0), &df)
+		options.Prefix = f.encodeKey(prefix)
+		options.Guide = f.someGuide()
+		if err := f.dict.WriteDOT(os.Stdout, options); err != nil {
+			log.Fatalf("error: %v\n", err)
+		}
+		return
+	}
+
+	buildOptions := dawg.NewBuildOptions()
+	buildOptions.Format = inputFormat(format)
+	buildOptions.OptionalValues = true
+	buildOptions.Sort = sortLexicon
+	buildOptions.Utfc = df.utfc
+
+	file := openInput(flags.Arg(0))
+	set, err := dawg.BuildFrom(file, buildOptions)
+	file.Close()
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+	options.Prefix = prefix
+	if df.utfc {
+		options.Prefix = string(dawg.UtfcEncode(prefix))
+	}
+	if err := set.Dawg.WriteDOT(os.Stdout, options); err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+}
@@ -2,321 +2,328 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"sort"
-	"strconv"
 	"strings"
 
 	"github.com/deNULL/dawg"
 )
 
-var optTab bool
-var optGuide bool
-var optRanked bool
-var optBuild bool
-var optSort bool
-var optUtfc bool
-var optIndex bool
-
-var optLexicon string
-var optDictionary string
-
-var fileLexicon *os.File
-var fileDictionary *os.File
-var scanner *bufio.Scanner
-
-var builder *dawg.DawgBuilder
-
-func processLine(line string) bool {
-	if len(line) == 0 {
-		return false
-	}
-
-	//fmt.Printf("inserting %s: %v\n", line, Encode(line))
+type command struct {
+	name  string
+	args  string
+	about string
+	run   func(args []string)
+}
 
-	var key string = line
-	var val int32 = 0
-	if optTab {
-		parts := strings.Split(line, "\t")
-		key = parts[0]
-		value, err := strconv.Atoi(parts[1])
-		if err != nil {
-			log.Fatal(err)
-		}
-		if value < 0 {
-			fmt.Printf("warning: negative value is replaced by 0: %d\n", value)
-			value = 0
-		} else if value > dawg.MaxValue {
-			fmt.Printf("warning: too large value is replaced by %d\n", dawg.MaxValue)
-			value = dawg.MaxValue
-		}
-		val = int32(value)
+var commands []command
+
+func init() {
+	commands = []command{
+		{"build", "[lexicon] [dictionary]", "build a dictionary from a lexicon", handleBuild},
+		{"lookup", "dictionary [key...]", "find values of keys", handleLookup},
+		{"prefixes", "dictionary [key...]", "find keys which are prefixes of given keys", handlePrefixes},
+		{"complete", "dictionary [prefix...]", "find keys which start with given prefixes", handleComplete},
+		{"topk", "dictionary [prefix...]", "find best completions by ranked guide", handleTopK},
+		{"index", "dictionary [key...]", "map keys to indices and back", handleIndex},
+		{"stats", "dictionary", "print sizes of a dictionary and its parts", handleStats},
+		{"verify", "dictionary", "check consistency of a dictionary", handleVerify},
+		{"dump", "dictionary", "write all keys and values in key order", handleDump},
+		{"diff", "old new", "list keys added, removed or changed between dictionaries", handleDiff},
+		{"convert", "dictionary [dictionary]", "rebuild a dictionary with other guide, index or encoding", handleConvert},
+		{"serve", "dictionary", "answer queries over HTTP with JSON responses", handleServe},
+		{"repl", "dictionary", "explore a dictionary interactively", handleRepl},
+		{"bench", "dictionary queries", "measure speed of queries", handleBench},
+		{"dot", "dictionary", "draw a dictionary or a dawg in GraphViz DOT format", handleDot},
 	}
+}
 
-	var bytes []uint8
-	if optUtfc {
-		bytes = dawg.UtfcEncode(key)
-	} else {
-		bytes = []uint8(key)
-	}
-	if !builder.InsertKeyValue(bytes, len(bytes), val) {
-		//fmt.Printf("key %s: %v", line, bytes)
-		log.Fatalf("error: failed to insert key: %s\n", key)
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: dawgtool command [flags] [arguments]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", c.name, c.about)
 	}
-
-	return true
+	fmt.Fprintf(os.Stderr, "\nrun dawgtool command -h for flags of a command\n")
+	os.Exit(2)
 }
 
-func handleBuildDict() {
-	builder = dawg.NewDawgBuilder()
-
-	var keyCount int = 0
-	if optSort {
-		var buffer []string = []string{}
-		for scanner.Scan() {
-			buffer = append(buffer, scanner.Text())
-		}
-		sort.SliceStable(buffer, func(i int, j int) bool {
-			if !optUtfc {
-				return buffer[i] < buffer[j]
-			}
-			bytes1 := dawg.UtfcEncode(buffer[i])
-			bytes2 := dawg.UtfcEncode(buffer[j])
-			length := len(bytes1)
-			if len(bytes2) < length {
-				length = len(bytes2)
-			}
-			for i := 0; i < length; i++ {
-				if bytes1[i] < bytes2[i] {
-					return true
-				} else if bytes1[i] > bytes2[i] {
-					return false
-				}
-			}
-			if len(bytes1) < len(bytes2) {
-				return true
-			}
-			return false
-		})
-
-		for i := 0; i < len(buffer); i++ {
-			if processLine(buffer[i]) {
-				keyCount++
-				if keyCount%10000 == 0 {
-					fmt.Printf("no. keys: %d\n", keyCount)
-				}
-			}
-		}
-	} else {
-		for scanner.Scan() {
-			var line string = scanner.Text()
-			if processLine(line) {
-				keyCount++
-				if keyCount%10000 == 0 {
-					fmt.Printf("no. keys: %d\n", keyCount)
-				}
+// Creates flags of a command with its usage. Queries and lexicons are read
+// from stdin if they are not given, and "-" means stdin or stdout in place of
+// a file.
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		for _, c := range commands {
+			if c.name == name {
+				fmt.Fprintf(os.Stderr, "usage: dawgtool %s [flags] %s\n\n%s\n\nflags:\n", c.name, c.args, c.about)
 			}
 		}
+		flags.PrintDefaults()
 	}
+	return flags
+}
 
-	d := dawg.NewDawg()
-	builder.Finish(d)
-
-	fmt.Printf("no. keys: %d\n", keyCount)
-	fmt.Printf("no. states: %d\n", d.NumOfStates())
-	fmt.Printf("no. transitions: %d\n", d.NumOfTransitions())
-	fmt.Printf("no. merged states: %d\n", d.NumOfMergedStates())
-	fmt.Printf("no. merging states: %d\n", d.NumOfMergingStates())
-	fmt.Printf("no. merged transitions: %d\n", d.NumOfMergedTransitions())
-
-	var numOfUnusedUnits uint32
-	dict := d.BuildWithUnused(&numOfUnusedUnits)
-	if dict == nil {
-		log.Fatalf("error: failed to build dictionary\n")
-	}
+// Flags describing parts of a dictionary file.
+type dictFlags struct {
+	guide      bool
+	ranked     bool
+	index      bool
+	aggregates bool
+	utfc       bool
+}
 
-	var unusedRatio float64 = 100.0 * float64(numOfUnusedUnits) / float64(dict.Size())
+func (df *dictFlags) register(flags *flag.FlagSet) {
+	flags.BoolVar(&df.guide, "g", false, "dictionary has guide")
+	flags.BoolVar(&df.ranked, "r", false, "dictionary has ranked guide")
+	flags.BoolVar(&df.index, "i", false, "dictionary has index")
+	flags.BoolVar(&df.aggregates, "A", false, "dictionary has aggregates of values after index")
+	flags.BoolVar(&df.utfc, "u", false, "keys are encoded in utf-c instead of utf-8")
+}
 
-	fmt.Printf("no. elements: %d\n", dict.Size())
-	fmt.Printf("no. unused elements: %d (%.2f%%)\n", numOfUnusedUnits, unusedRatio)
-	fmt.Printf("dictionary size: %d\n", dict.TotalSize())
+// Dictionary with parts which follow it in a file.
+type dictFile struct {
+	dict        *dawg.Dictionary
+	guide       *dawg.Guide
+	rankedGuide *dawg.RankedGuide
+	index       *dawg.Index
+	aggregates  *dawg.AggregateIndex
+	utfc        bool
+}
 
-	/*for i := 0; i < dict.size; i++ {
-		fmt.Printf("%.2d: %.8x leaf? %v ext? %v hleaf? %v label? %d (%c) offset = %d => %d, value = %d\n", i, dict.units[i], dictIsLeaf(dict.units[i]), dictHasExtBit(dict.units[i]), dictHasLeaf(dict.units[i]), dictLabel(dict.units[i]), dictLabel(dict.units[i]), dictOffset(dict.units[i]), dictOffset(dict.units[i])^baseType(i), dictValue(dict.units[i]))
-	}*/
+func (f *dictFile) someGuide() dawg.SomeGuide {
+	if f.rankedGuide != nil {
+		return f.rankedGuide
+	} else if f.guide != nil {
+		return f.guide
+	}
+	return nil
+}
 
-	if !dict.Write(fileDictionary) {
-		log.Fatalf("error: failed to write Dictionary")
+func (f *dictFile) indexer() *dawg.Indexer {
+	if f.index == nil {
+		log.Fatalf("error: index is required (-i with -g or -r)\n")
 	}
+	return dawg.NewIndexerWithAggregates(f.dict, f.someGuide(), f.index, f.aggregates)
+}
 
-	// Builds a guide
-	var guide dawg.SomeGuide
-	if optRanked {
-		guide = dawg.BuildRankedGuide(d, dict)
-		if guide == nil {
-			log.Fatalf("error: failed to build Guide\n")
-		}
+// Encodes a key without shared buffers, so that requests of a server can do
+// it concurrently.
+func (f *dictFile) encodeKey(key string) string {
+	if f.utfc {
+		return string(dawg.UtfcAppend(nil, key))
+	}
+	return key
+}
 
-		fmt.Printf("no. units: %d\n", guide.Size())
-		fmt.Printf("guide size: %d\n", guide.TotalSize())
+func (f *dictFile) decodeKey(key []byte) string {
+	if f.utfc {
+		return dawg.UtfcDecode(key)
+	}
+	return string(key)
+}
 
-		if !guide.Write(fileDictionary) {
-			log.Fatalf("error: failed to write RankedGuide\n")
-		}
-	} else if optGuide {
-		guide = dawg.BuildGuide(d, dict)
-		if guide == nil {
-			log.Fatalf("error: failed to build Guide\n")
-		}
+func openInput(path string) io.ReadCloser {
+	if path == "-" {
+		return io.NopCloser(os.Stdin)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return file
+}
 
-		fmt.Printf("no. units: %d\n", guide.Size())
-		fmt.Printf("guide size: %d\n", guide.TotalSize())
+// Reads a dictionary with a guide and an index as described by flags, and
+// checks that all of them agree, so that queries cannot fail on them later.
+func loadDict(path string, df *dictFlags) *dictFile {
+	f := readDict(path, df)
+	if err := f.validate(); err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+	return f
+}
 
-		if !guide.Write(fileDictionary) {
-			log.Fatalf("error: failed to write Guide\n")
+// Checks every part of a dictionary file.
+func (f *dictFile) validate() error {
+	if err := f.dict.Validate(); err != nil {
+		return fmt.Errorf("invalid Dictionary: %w", err)
+	}
+	if guide := f.someGuide(); guide != nil {
+		if err := dawg.ValidateGuide(guide, f.dict); err != nil {
+			return fmt.Errorf("invalid guide: %w", err)
 		}
 	}
-
-	if guide != nil && optIndex {
-		index := dawg.BuildIndex(dict, guide)
-		if index == nil {
-			log.Fatalf("error: failed to build Index\n")
+	if f.index != nil {
+		if err := f.index.Validate(f.dict, f.someGuide()); err != nil {
+			return fmt.Errorf("invalid Index: %w", err)
 		}
-
-		fmt.Printf("no. index units: %d\n", index.Size())
-		fmt.Printf("index size: %d\n", index.TotalSize())
-
-		if !index.Write(fileDictionary) {
-			log.Fatalf("error: failed to write Index\n")
+	}
+	if f.aggregates != nil {
+		if err := f.aggregates.Validate(f.dict); err != nil {
+			return fmt.Errorf("invalid AggregateIndex: %w", err)
 		}
 	}
+	return nil
 }
 
-func handleLoadDict() {
-	dict := dawg.ReadDictionary(fileDictionary)
-	if dict == nil {
+// Reads a dictionary with its parts without checking them.
+func readDict(path string, df *dictFlags) *dictFile {
+	file := openInput(path)
+	defer file.Close()
+	r := bufio.NewReader(file)
+
+	f := &dictFile{utfc: df.utfc}
+	if f.dict = dawg.ReadDictionary(r); f.dict == nil {
 		log.Fatalf("error: failed to read Dictionary\n")
 	}
-
-	var guide dawg.SomeGuide
-	var completer dawg.SomeCompleter
-	if optRanked {
-		var rguide = dawg.ReadRankedGuide(fileDictionary)
-		if rguide == nil {
+	if df.ranked {
+		if f.rankedGuide = dawg.ReadRankedGuide(r); f.rankedGuide == nil {
 			log.Fatalf("error: failed to read RankedGuide\n")
 		}
-		completer = dawg.NewRankedCompleter(dict, rguide)
-		guide = rguide
-	} else if optGuide {
-		var sguide = dawg.ReadGuide(fileDictionary)
-		if sguide == nil {
+		if f.rankedGuide.Order() == dawg.CustomOrder {
+			log.Fatalf("error: RankedGuide is built with a custom comparator\n")
+		}
+	} else if df.guide {
+		if f.guide = dawg.ReadGuide(r); f.guide == nil {
 			log.Fatalf("error: failed to read Guide\n")
 		}
-		completer = dawg.NewCompleter(dict, sguide)
-		guide = sguide
 	}
-
-	var indexer dawg.Indexer
-	if optIndex && guide != nil {
-		var index = dawg.ReadIndex(fileDictionary)
-		if index == nil {
+	if df.index && f.someGuide() != nil {
+		if f.index = dawg.ReadIndex(r); f.index == nil {
 			log.Fatalf("error: failed to read Index\n")
 		}
-		indexer = *dawg.NewIndexer(dict, guide, index)
+		if df.aggregates {
+			if f.aggregates = dawg.ReadAggregateIndex(r); f.aggregates == nil {
+				log.Fatalf("error: failed to read AggregateIndex\n")
+			}
+		}
 	}
+	return f
+}
 
-	for scanner.Scan() {
-		var key string = scanner.Text()
-
-		fmt.Printf("%s:", key)
-		var index uint32 = dict.Root()
+// Prints records as tab separated values or as JSON objects, one per line.
+type printer struct {
+	w    *bufio.Writer
+	json bool
+}
 
-		if optRanked || optGuide {
-			if optIndex {
-				var idx = indexer.StringToIndex(key)
-				if idx == dawg.NotFound {
-					fmt.Printf(" (not found)")
-				} else if idx == dawg.Failed {
-					fmt.Printf(" (failed)")
-				} else {
-					fmt.Printf(" (#%d)", idx)
-				}
-			}
-			if dict.FollowString(key, &index) {
-				completer.Start(index)
-				for completer.Next() {
-					fmt.Printf(" %s%s = %d;", key, completer.Key(), completer.Value())
-				}
-			}
-		} else {
-			for i := 0; i < len(key); i++ {
-				if !dict.Follow(key[i], &index) {
-					break
-				}
+func registerOutput(flags *flag.FlagSet) *string {
+	return flags.String("o", "tsv", "output format: tsv or json")
+}
 
-				// Reads a value
-				if dict.HasValue(index) {
-					fmt.Printf(" %s = %d;", key[:i+1], dict.Value(index))
-				}
-			}
-		}
-		fmt.Println()
+func newPrinter(format string) *printer {
+	if format != "tsv" && format != "json" {
+		log.Fatalf("error: unknown output format: %s\n", format)
 	}
+	return &printer{w: bufio.NewWriter(os.Stdout), json: format == "json"}
+}
 
-	if optIndex {
-		fmt.Printf("Total words: %d\n", indexer.TotalCount())
+// Prints a record of names and values which follow each other. Missing
+// values are nil.
+func (p *printer) print(fields ...interface{}) {
+	line, err := p.format(fields...)
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+	if _, err := p.w.WriteString(line); err != nil {
+		log.Fatal(err)
+	}
+}
 
-		for i := 0; i < int(indexer.TotalCount()); i++ {
-			fmt.Printf("%d: %s\n", i, indexer.IndexToString(uint32(i)))
+// Formats a record as a line. Fails for values with tabs or line breaks in
+// TSV, which could not be told from separators.
+func (p *printer) format(fields ...interface{}) (string, error) {
+	var b strings.Builder
+	if p.json {
+		b.WriteByte('{')
+	}
+	for i := 0; i+1 < len(fields); i += 2 {
+		if i != 0 && p.json {
+			b.WriteByte(',')
+		} else if i != 0 {
+			b.WriteByte('\t')
 		}
+		if p.json {
+			name, _ := json.Marshal(fields[i])
+			value, _ := json.Marshal(fields[i+1])
+			b.Write(name)
+			b.WriteByte(':')
+			b.Write(value)
+		} else if fields[i+1] != nil {
+			var value string = fmt.Sprint(fields[i+1])
+			if strings.ContainsAny(value, "\t\n\r") {
+				return "", fmt.Errorf("%s %q has a tab or a line break, use -o json", fields[i], value)
+			}
+			b.WriteString(value)
+		}
+	}
+	if p.json {
+		b.WriteByte('}')
 	}
+	b.WriteByte('\n')
+	return b.String(), nil
 }
 
-func main() {
-	flag.BoolVar(&optBuild, "b", false, "build dictionary")
-	flag.BoolVar(&optTab, "t", false, "handle tab as separator")
-	flag.BoolVar(&optGuide, "g", false, "build/load dictionary with guide")
-	flag.BoolVar(&optRanked, "r", false, "build/load dictionary with ranked guide")
-	flag.BoolVar(&optIndex, "i", false, "build/load dictionary with indices")
-	flag.BoolVar(&optSort, "s", false, "sort lexicon before building dict")
-	flag.BoolVar(&optUtfc, "u", false, "use utf-c instead of utf-8 for encoding keys")
-	flag.StringVar(&optLexicon, "l", "-", "lexicon file")
-	flag.StringVar(&optDictionary, "d", "-", "dictionary file")
-
-	flag.Parse()
+func (p *printer) flush() {
+	if err := p.w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
 
-	if optLexicon == "-" && flag.NArg() > 0 {
-		optLexicon = flag.Arg(0)
+// Calls fn for queries given as arguments, or for lines of stdin if there
+// are none. Results are flushed after each line, so that the tool can be
+// used through pipes.
+func forEachQuery(args []string, p *printer, fn func(query string)) {
+	if len(args) != 0 {
+		for _, query := range args {
+			fn(query)
+		}
+		p.flush()
+		return
 	}
 
-	if optDictionary == "-" && flag.NArg() > 1 {
-		optDictionary = flag.Arg(1)
+	r := bufio.NewReader(os.Stdin)
+	for {
+		query, err := readQuery(r)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			log.Fatal(err)
+		}
+		fn(query)
+		p.flush()
 	}
+}
 
-	var err error
-	fileLexicon, err = os.Open(optLexicon)
-	if err != nil {
-		log.Fatal(err)
+// Reads a line without a line break. Returns io.EOF after the last line.
+func readQuery(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err == io.EOF && len(line) != 0 {
+		err = nil
 	}
-	defer fileLexicon.Close()
-	scanner = bufio.NewScanner(fileLexicon)
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), err
+}
 
-	if optBuild {
-		fileDictionary, err = os.Create(optDictionary)
-	} else {
-		fileDictionary, err = os.Open(optDictionary)
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
 	}
-	if err != nil {
-		log.Fatal(err)
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			c.run(os.Args[2:])
+			return
+		}
 	}
-	defer fileDictionary.Close()
-
-	if optBuild {
-		handleBuildDict()
-	} else {
-		handleLoadDict()
+	if strings.HasPrefix(os.Args[1], "-") && os.Args[1] != "-h" {
+		fmt.Fprintf(os.Stderr, "flags before a command are no longer supported, see README.md for commands replacing them\n")
+	} else if os.Args[1] != "help" && os.Args[1] != "-h" {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", os.Args[1])
 	}
+	usage()
 }
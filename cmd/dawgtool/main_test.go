@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/deNULL/dawg"
+)
+
+func TestPrinter(t *testing.T) {
+	var tests = []struct {
+		json     bool
+		fields   []interface{}
+		expected string
+	}{
+		{false, []interface{}{"key", "apple", "value", int64(-5)}, "apple\t-5\n"},
+		{false, []interface{}{"key", "apple", "found", false, "value", nil}, "apple\tfalse\t\n"},
+		{true, []interface{}{"key", "a\tb", "value", nil}, "{\"key\":\"a\\tb\",\"value\":null}\n"},
+	}
+	for i, test := range tests {
+		p := &printer{json: test.json}
+		line, err := p.format(test.fields...)
+		if err != nil || line != test.expected {
+			t.Errorf("Test %d: expected %q, got %q (%v)", i, test.expected, line, err)
+		}
+	}
+
+	p := &printer{}
+	for _, key := range []string{"a\tb", "a\nb", "a\r"} {
+		if _, err := p.format("key", key, "value", 1); err == nil {
+			t.Errorf("Key %q is printed as TSV", key)
+		}
+	}
+}
+
+func TestReadQuery(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("apple\r\n\nbanana\ncherry"))
+	var expected = []string{"apple", "", "banana", "cherry"}
+	for _, query := range expected {
+		line, err := readQuery(r)
+		if err != nil || line != query {
+			t.Errorf("Expected %q, got %q (%v)", query, line, err)
+		}
+	}
+	if _, err := readQuery(r); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+}
+
+// Builds a dictionary with default flags of build and dumps it.
+func buildAndDump(t *testing.T, lexicon string, args ...string) string {
+	var lf lexiconFlags
+	var pf partFlags
+	flags := newFlagSet("build")
+	lf.register(flags)
+	pf.register(flags, "")
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	options := lf.options()
+	pf.apply(options)
+	set, err := dawg.BuildFrom(strings.NewReader(lexicon), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var path string = filepath.Join(t.TempDir(), "test.dic")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !set.Write(file) || file.Close() != nil {
+		t.Fatalf("Failed to write dictionary")
+	}
+
+	var df dictFlags
+	df.guide = pf.guide
+	df.utfc = lf.utfc
+	f := loadDict(path, &df)
+	var buf bytes.Buffer
+	p := &printer{w: bufio.NewWriter(&buf)}
+	dump(f, p)
+	p.flush()
+	return buf.String()
+}
+
+func TestBuildDump(t *testing.T) {
+	var lexicon string = "a\t1\nab\t0\nb\t-3\nябл\t7\n"
+	if output := buildAndDump(t, lexicon, "-vt", "-g"); output != lexicon {
+		t.Errorf("Expected %q, got %q", lexicon, output)
+	}
+	if output := buildAndDump(t, lexicon, "-vt", "-u"); output != lexicon {
+		t.Errorf("UTF-C: expected %q, got %q", lexicon, output)
+	}
+	if output := buildAndDump(t, "b\na\n", "-s"); output != "a\t0\nb\t0\n" {
+		t.Errorf("Unexpected dump of plain keys %q", output)
+	}
+}
+
+func TestBuildColumns(t *testing.T) {
+	var tests = []struct {
+		lexicon  string
+		args     []string
+		expected string
+	}{
+		{"apple\t3\nbanana\n", nil, "apple\t3\nbanana\t0\n"},
+		{"3\tapple\n5\tbanana\n", []string{"-kc", "2", "-vc", "1"}, "apple\t3\nbanana\t5\n"},
+		{"apple\tred\nbanana\tyellow\n", []string{"-novalues"}, "apple\t0\nbanana\t0\n"},
+		{"{\"key\": \"apple\", \"value\": \"red\"}\n", []string{"-f", "jsonl", "-novalues"}, "apple\t0\n"},
+	}
+	for i, test := range tests {
+		if output := buildAndDump(t, test.lexicon, test.args...); output != test.expected {
+			t.Errorf("Test %d: expected %q, got %q", i, test.expected, output)
+		}
+	}
+}
+
+func TestDictFileValidate(t *testing.T) {
+	var build = func(lexicon string) *dawg.DictionarySet {
+		set, err := dawg.BuildFrom(strings.NewReader(lexicon), &dawg.BuildOptions{RankedGuide: true, Index: true, Aggregates: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return set
+	}
+	set := build("a\t1\nab\t2\nb\t3\n")
+	other := build("abc\t1\nb\t5\nc\t3\n")
+
+	f := &dictFile{dict: set.Dictionary, rankedGuide: set.RankedGuide, index: set.Index, aggregates: set.Aggregates}
+	if err := f.validate(); err != nil {
+		t.Fatal(err)
+	}
+	var parts = []*dictFile{
+		{dict: set.Dictionary, rankedGuide: other.RankedGuide},
+		{dict: set.Dictionary, rankedGuide: set.RankedGuide, index: other.Index},
+		{dict: set.Dictionary, rankedGuide: set.RankedGuide, index: set.Index, aggregates: other.Aggregates},
+	}
+	for i, f := range parts {
+		if f.validate() == nil {
+			t.Errorf("Test %d: parts of another dictionary are accepted", i)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/deNULL/dawg"
+)
+
+func handleLookup(args []string) {
+	var df dictFlags
+	flags := newFlagSet("lookup")
+	df.register(flags)
+	output := registerOutput(flags)
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return
+	}
+
+	f := loadDict(flags.Arg(0), &df)
+	p := newPrinter(*output)
+	forEachQuery(flags.Args()[1:], p, func(query string) {
+		var value int64
+		if f.dict.FindStringValue64(f.encodeKey(query), &value) {
+			p.print("key", query, "found", true, "value", value)
+		} else {
+			p.print("key", query, "found", false, "value", nil)
+		}
+	})
+}
+
+func handlePrefixes(args []string) {
+	var df dictFlags
+	flags := newFlagSet("prefixes")
+	df.register(flags)
+	output := registerOutput(flags)
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return
+	}
+
+	f := loadDict(flags.Arg(0), &df)
+	p := newPrinter(*output)
+	forEachQuery(flags.Args()[1:], p, func(query string) {
+		var key string = f.encodeKey(query)
+		var index uint32 = f.dict.Root()
+		for i := 0; i <= len(key); i++ {
+			if i != 0 && !f.dict.Follow(key[i-1], &index) {
+				break
+			}
+			if f.dict.HasValue(index) {
+				p.print("query", query, "key", f.decodeKey([]byte(key[:i])), "value", f.dict.Value64(index))
+			}
+		}
+	})
+}
+
+// Completer which starts with a prefix and keeps it in keys.
+type prefixCompleter interface {
+	dawg.SomeCompleter
+	StartString(index uint32, prefix string)
+}
+
+// Calls fn for up to limit completions of each query, or for all of them if
+// limit is not positive.
+func complete(f *dictFile, c prefixCompleter, args []string, p *printer, limit int, fn func(query string, rank int, key string, value int64)) {
+	forEachQuery(args, p, func(query string) {
+		var prefix string = f.encodeKey(query)
+		var index uint32 = f.dict.Root()
+		if !f.dict.FollowString(prefix, &index) {
+			return
+		}
+		c.StartString(index, prefix)
+		for rank := 1; (limit <= 0 || rank <= limit) && c.Next(); rank++ {
+			fn(query, rank, f.decodeKey([]byte(c.Key()[:c.Length()])), c.Value64())
+		}
+	})
+}
+
+func handleComplete(args []string) {
+	var df dictFlags
+	var limit int
+	flags := newFlagSet("complete")
+	df.register(flags)
+	flags.IntVar(&limit, "n", 0, "maximum number of completions of each prefix (0 for no limit)")
+	output := registerOutput(flags)
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return
+	}
+
+	f := loadDict(flags.Arg(0), &df)
+	var c prefixCompleter
+	if f.rankedGuide != nil {
+		c = dawg.NewRankedCompleter(f.dict, f.rankedGuide)
+	} else if f.guide != nil {
+		c = dawg.NewCompleter(f.dict, f.guide)
+	} else {
+		log.Fatalf("error: guide is required (-g or -r)\n")
+	}
+
+	p := newPrinter(*output)
+	complete(f, c, flags.Args()[1:], p, limit, func(query string, rank int, key string, value int64) {
+		p.print("query", query, "key", key, "value", value)
+	})
+}
+
+func handleTopK(args []string) {
+	var df dictFlags
+	var k int
+	flags := newFlagSet("topk")
+	df.register(flags)
+	flags.IntVar(&k, "k", 10, "number of completions of each prefix")
+	output := registerOutput(flags)
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return
+	}
+
+	f := loadDict(flags.Arg(0), &df)
+	if f.rankedGuide == nil {
+		log.Fatalf("error: ranked guide is required (-r)\n")
+	}
+	c := dawg.NewRankedCompleter(f.dict, f.rankedGuide)
+
+	p := newPrinter(*output)
+	complete(f, c, flags.Args()[1:], p, k, func(query string, rank int, key string, value int64) {
+		p.print("query", query, "rank", rank, "key", key, "value", value)
+	})
+}
+
+func handleIndex(args []string) {
+	var df dictFlags
+	var reverse bool
+	flags := newFlagSet("index")
+	df.register(flags)
+	flags.BoolVar(&reverse, "n", false, "map indices to keys instead of keys to indices")
+	output := registerOutput(flags)
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return
+	}
+
+	f := loadDict(flags.Arg(0), &df)
+	indexer := f.indexer()
+	p := newPrinter(*output)
+	forEachQuery(flags.Args()[1:], p, func(query string) {
+		if !reverse {
+			var i uint32 = indexer.StringToIndex(f.encodeKey(query))
+			if i == dawg.NotFound || i == dawg.Failed {
+				p.print("key", query, "index", nil)
+			} else {
+				p.print("key", query, "index", i)
+			}
+			return
+		}
+
+		i, err := strconv.ParseUint(query, 10, 32)
+		if err != nil || uint32(i) >= indexer.TotalCount() {
+			p.print("index", query, "key", nil)
+			return
+		}
+		p.print("index", i, "key", f.decodeKey(indexer.IndexToBytes(uint32(i))))
+	})
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/deNULL/dawg"
+)
+
+const replHelp = `commands:
+  get key           print a value of a key below the current node
+  has key           check if there is a key below the current node
+  prefixes key      list keys which are prefixes of a key below the current node
+  complete [n] [p]  list up to n keys below the current node starting with p
+  rank key          print an index of a key below the current node
+  unrank i          print a key with an index
+  children          list labels of transitions from the current node
+  cd key            move down by a key, to the root with /, or up with ..
+  up [n]            move up by n characters
+  pwd               print a path to the current node
+  help              print this help
+  quit              exit
+`
+
+// Explores a dictionary node by node. The current node is kept as a path of
+// characters from the root, so that keys in UTF-C are always encoded whole.
+type repl struct {
+	file      *dictFile
+	completer prefixCompleter
+	indexer   *dawg.Indexer
+	path      string
+	w         *bufio.Writer
+	completeN int
+}
+
+// Follows a key below the current node from the root.
+func (r *repl) follow(key string) (string, uint32, bool) {
+	var fullKey string = r.path + key
+	var index uint32 = r.file.dict.Root()
+	return fullKey, index, r.file.dict.FollowString(r.file.encodeKey(fullKey), &index)
+}
+
+func (r *repl) printf(format string, args ...interface{}) {
+	fmt.Fprintf(r.w, format, args...)
+}
+
+func (r *repl) get(key string) {
+	fullKey, index, ok := r.follow(key)
+	if !ok || !r.file.dict.HasValue(index) {
+		r.printf("%s: not found\n", fullKey)
+		return
+	}
+	r.printf("%s: %d\n", fullKey, r.file.dict.Value64(index))
+}
+
+func (r *repl) has(key string) {
+	fullKey, index, ok := r.follow(key)
+	r.printf("%s: %t\n", fullKey, ok && r.file.dict.HasValue(index))
+}
+
+func (r *repl) prefixes(key string) {
+	var dict *dawg.Dictionary = r.file.dict
+	var encoded string = r.file.encodeKey(r.path + key)
+	var index uint32 = dict.Root()
+	for i := 0; i <= len(encoded); i++ {
+		if i != 0 && !dict.Follow(encoded[i-1], &index) {
+			break
+		}
+		if dict.HasValue(index) {
+			r.printf("%s: %d\n", r.file.decodeKey([]byte(encoded[:i])), dict.Value64(index))
+		}
+	}
+}
+
+func (r *repl) complete(args []string) {
+	if r.completer == nil {
+		r.printf("error: guide is required (-g or -r)\n")
+		return
+	}
+	var n int = r.completeN
+	if len(args) != 0 {
+		if i, err := strconv.Atoi(args[0]); err == nil {
+			n = i
+			args = args[1:]
+		}
+	}
+
+	fullKey, index, ok := r.follow(strings.Join(args, " "))
+	if !ok {
+		return
+	}
+	r.completer.StartString(index, r.file.encodeKey(fullKey))
+	for i := 0; (n <= 0 || i < n) && r.completer.Next(); i++ {
+		var key string = r.completer.Key()[:r.completer.Length()]
+		r.printf("%s: %d\n", r.file.decodeKey([]byte(key)), r.completer.Value64())
+	}
+}
+
+func (r *repl) rank(key string) {
+	if r.indexer == nil {
+		r.printf("error: index is required (-i)\n")
+		return
+	}
+	var fullKey string = r.path + key
+	var i uint32 = r.indexer.StringToIndex(r.file.encodeKey(fullKey))
+	if i == dawg.NotFound || i == dawg.Failed {
+		r.printf("%s: not found\n", fullKey)
+		return
+	}
+	r.printf("%s: #%d\n", fullKey, i)
+}
+
+func (r *repl) unrank(arg string) {
+	if r.indexer == nil {
+		r.printf("error: index is required (-i)\n")
+		return
+	}
+	i, err := strconv.ParseUint(arg, 10, 32)
+	if err != nil || uint32(i) >= r.indexer.TotalCount() {
+		r.printf("#%s: not found\n", arg)
+		return
+	}
+	r.printf("#%d: %s\n", i, r.file.decodeKey(r.indexer.IndexToBytes(uint32(i))))
+}
+
+// Lists transitions in the order of a guide, or by labels without it, with
+// numbers of keys below them if there is an index.
+func (r *repl) children() {
+	_, index, ok := r.follow("")
+	if !ok {
+		return
+	}
+	var dict *dawg.Dictionary = r.file.dict
+	for _, label := range dict.AppendGuideLabels(r.file.someGuide(), index, nil) {
+		if label == 0 {
+			r.printf("(end of key)\n")
+			continue
+		}
+		var text string = string(rune(label))
+		if label < 0x20 || label >= 0x7f {
+			text = fmt.Sprintf("0x%02x", label)
+		}
+		var childIndex uint32 = index
+		dict.Follow(label, &childIndex)
+		if r.file.index != nil {
+			r.printf("%s (%d keys)\n", text, r.file.index.ChildCount(childIndex))
+		} else {
+			r.printf("%s\n", text)
+		}
+	}
+}
+
+func (r *repl) cd(key string) {
+	switch key {
+	case "/":
+		r.path = ""
+		return
+	case "..":
+		r.up("1")
+		return
+	}
+	fullKey, _, ok := r.follow(key)
+	if !ok {
+		r.printf("%s: not found\n", fullKey)
+		return
+	}
+	r.path = fullKey
+}
+
+func (r *repl) up(arg string) {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		r.printf("error: %s is not a number\n", arg)
+		return
+	}
+	for ; n > 0 && len(r.path) != 0; n-- {
+		_, size := utf8.DecodeLastRuneInString(r.path)
+		r.path = r.path[:len(r.path)-size]
+	}
+}
+
+// Runs a command, and returns false if the session is over.
+func (r *repl) run(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return true
+	}
+	var arg string = strings.Join(fields[1:], " ")
+	switch fields[0] {
+	case "get":
+		r.get(arg)
+	case "has":
+		r.has(arg)
+	case "prefixes":
+		r.prefixes(arg)
+	case "complete":
+		r.complete(fields[1:])
+	case "rank":
+		r.rank(arg)
+	case "unrank":
+		r.unrank(arg)
+	case "children":
+		r.children()
+	case "cd":
+		r.cd(arg)
+	case "up":
+		if arg == "" {
+			arg = "1"
+		}
+		r.up(arg)
+	case "pwd":
+		r.printf("/%s\n", r.path)
+	case "help":
+		r.printf("%s", replHelp)
+	case "quit", "exit":
+		return false
+	default:
+		r.printf("unknown command: %s (try help)\n", fields[0])
+	}
+	return true
+}
+
+func handleRepl(args []string) {
+	var df dictFlags
+	var completeN int
+	flags := newFlagSet("repl")
+	df.register(flags)
+	flags.IntVar(&completeN, "n", 10, "default number of completions")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return
+	}
+
+	f := loadDict(flags.Arg(0), &df)
+	r := &repl{file: f, w: bufio.NewWriter(os.Stdout), completeN: completeN}
+	if f.guide != nil {
+		r.completer = dawg.NewCompleter(f.dict, f.guide)
+	} else if f.rankedGuide != nil {
+		r.completer = dawg.NewRankedCompleter(f.dict, f.rankedGuide)
+	}
+	if f.index != nil {
+		r.indexer = f.indexer()
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	for {
+		r.printf("/%s> ", r.path)
+		r.w.Flush()
+		line, err := in.ReadString('\n')
+		if !r.run(strings.TrimSpace(line)) {
+			break
+		}
+		if err == io.EOF {
+			r.printf("\n")
+			break
+		} else if err != nil {
+			log.Fatal(err)
+		}
+	}
+	r.w.Flush()
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/deNULL/dawg"
+)
+
+// A key with its value in responses.
+type keyValue struct {
+	Key   string `json:"key"`
+	Value int64  `json:"value"`
+}
+
+// A query of any endpoint. Batches are lists of queries with ops set to
+// names of endpoints.
+type serverQuery struct {
+	Op     string `json:"op,omitempty"`
+	Key    string `json:"key,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+	Index  uint32 `json:"index,omitempty"`
+}
+
+type lookupResponse struct {
+	Key   string `json:"key"`
+	Found bool   `json:"found"`
+	Value *int64 `json:"value,omitempty"`
+}
+
+type listResponse struct {
+	Query   string     `json:"query"`
+	Results []keyValue `json:"results"`
+}
+
+type indexResponse struct {
+	Key   string  `json:"key"`
+	Index *uint32 `json:"index"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+var errNoGuide = errors.New("guide is not loaded")
+var errNoRankedGuide = errors.New("ranked guide is not loaded")
+var errNoIndex = errors.New("index is not loaded")
+var errUnknownOp = errors.New("unknown op")
+
+// Maximum size of a batch request in bytes.
+const maxBatchBytes = 4 << 20
+
+// Answers queries to a dictionary which is loaded once. Dictionaries, guides
+// and indexers are only read, so they are shared by requests, while
+// completers keep state and are taken from pools.
+type server struct {
+	file     *dictFile
+	indexer  *dawg.Indexer
+	maxLimit int
+	maxBatch int
+
+	completers       sync.Pool
+	rankedCompleters sync.Pool
+}
+
+func newServer(f *dictFile, maxLimit int, maxBatch int) *server {
+	s := &server{file: f, maxLimit: maxLimit, maxBatch: maxBatch}
+	if f.index != nil {
+		s.indexer = f.indexer()
+	}
+	if f.guide != nil {
+		s.completers.New = func() interface{} {
+			return dawg.NewCompleter(f.dict, f.guide)
+		}
+	}
+	if f.rankedGuide != nil {
+		s.rankedCompleters.New = func() interface{} {
+			if f.aggregates != nil {
+				return dawg.NewRankedCompleterWithAggregates(f.dict, f.rankedGuide, f.aggregates)
+			}
+			return dawg.NewRankedCompleter(f.dict, f.rankedGuide)
+		}
+	}
+	return s
+}
+
+// Limits a number of results, using the maximum if it is not positive.
+func (s *server) limit(limit int) int {
+	if limit <= 0 || limit > s.maxLimit {
+		return s.maxLimit
+	}
+	return limit
+}
+
+func (s *server) lookup(q *serverQuery) (interface{}, error) {
+	var value int64
+	if !s.file.dict.FindStringValue64(s.file.encodeKey(q.Key), &value) {
+		return &lookupResponse{Key: q.Key}, nil
+	}
+	return &lookupResponse{Key: q.Key, Found: true, Value: &value}, nil
+}
+
+func (s *server) prefixes(q *serverQuery) (interface{}, error) {
+	var dict *dawg.Dictionary = s.file.dict
+	var key string = s.file.encodeKey(q.Key)
+	var response *listResponse = &listResponse{Query: q.Key, Results: []keyValue{}}
+	var index uint32 = dict.Root()
+	for i := 0; i <= len(key); i++ {
+		if i != 0 && !dict.Follow(key[i-1], &index) {
+			break
+		}
+		if dict.HasValue(index) {
+			response.Results = append(response.Results, keyValue{s.file.decodeKey([]byte(key[:i])), dict.Value64(index)})
+		}
+	}
+	return response, nil
+}
+
+// Lists completions of a prefix with a completer from a pool.
+func (s *server) completions(pool *sync.Pool, q *serverQuery) (interface{}, error) {
+	var c prefixCompleter = pool.Get().(prefixCompleter)
+	defer pool.Put(c)
+
+	var response *listResponse = &listResponse{Query: q.Prefix, Results: []keyValue{}}
+	var prefix string = s.file.encodeKey(q.Prefix)
+	var index uint32 = s.file.dict.Root()
+	if !s.file.dict.FollowString(prefix, &index) {
+		return response, nil
+	}
+	c.StartString(index, prefix)
+	for limit := s.limit(q.Limit); len(response.Results) < limit && c.Next(); {
+		response.Results = append(response.Results, keyValue{s.file.decodeKey([]byte(c.Key()[:c.Length()])), c.Value64()})
+	}
+	return response, nil
+}
+
+// Completes a prefix in key order, or by ranks if there is no plain guide.
+func (s *server) complete(q *serverQuery) (interface{}, error) {
+	if s.completers.New != nil {
+		return s.completions(&s.completers, q)
+	} else if s.rankedCompleters.New != nil {
+		return s.completions(&s.rankedCompleters, q)
+	}
+	return nil, errNoGuide
+}
+
+// Finds the best completions of a prefix, pruning subtrees by aggregates if
+// they are loaded.
+func (s *server) topk(q *serverQuery) (interface{}, error) {
+	if s.rankedCompleters.New == nil {
+		return nil, errNoRankedGuide
+	}
+	var c *dawg.RankedCompleter = s.rankedCompleters.Get().(*dawg.RankedCompleter)
+	defer s.rankedCompleters.Put(c)
+
+	var response *listResponse = &listResponse{Query: q.Prefix, Results: []keyValue{}}
+	for _, result := range c.TopK(s.file.encodeKey(q.Prefix), s.limit(q.Limit), nil) {
+		response.Results = append(response.Results, keyValue{s.file.decodeKey(result.Key), result.Value})
+	}
+	return response, nil
+}
+
+func (s *server) rank(q *serverQuery) (interface{}, error) {
+	if s.indexer == nil {
+		return nil, errNoIndex
+	}
+	var i uint32 = s.indexer.StringToIndex(s.file.encodeKey(q.Key))
+	if i == dawg.NotFound || i == dawg.Failed {
+		return &indexResponse{Key: q.Key}, nil
+	}
+	return &indexResponse{Key: q.Key, Index: &i}, nil
+}
+
+func (s *server) unrank(q *serverQuery) (interface{}, error) {
+	if s.indexer == nil {
+		return nil, errNoIndex
+	}
+	if q.Index >= s.indexer.TotalCount() {
+		return &indexResponse{}, nil
+	}
+	var i uint32 = q.Index
+	return &indexResponse{Key: s.file.decodeKey(s.indexer.IndexToBytes(i)), Index: &i}, nil
+}
+
+func (s *server) handle(q *serverQuery) (interface{}, error) {
+	switch q.Op {
+	case "lookup":
+		return s.lookup(q)
+	case "prefixes":
+		return s.prefixes(q)
+	case "complete":
+		return s.complete(q)
+	case "topk":
+		return s.topk(q)
+	case "rank":
+		return s.rank(q)
+	case "unrank":
+		return s.unrank(q)
+	}
+	return nil, errUnknownOp
+}
+
+func writeJSON(w http.ResponseWriter, status int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("error: %v\n", err)
+	}
+}
+
+// Serves a single query given by URL parameters.
+func (s *server) handleQuery(op string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		q := &serverQuery{Op: op, Key: params.Get("key"), Prefix: params.Get("prefix")}
+		var err error
+		if limit := params.Get("limit"); limit != "" {
+			q.Limit, err = strconv.Atoi(limit)
+		}
+		if index := params.Get("index"); index != "" && err == nil {
+			var i uint64
+			i, err = strconv.ParseUint(index, 10, 32)
+			q.Index = uint32(i)
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, &errorResponse{err.Error()})
+			return
+		}
+
+		response, err := s.handle(q)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, &errorResponse{err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, response)
+	}
+}
+
+// Serves a JSON list of queries posted at once. Failed queries get errors
+// in place of their responses. Batches with too many queries or bytes are
+// refused as a whole.
+func (s *server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, &errorResponse{"batch requires POST"})
+		return
+	}
+	var queries []serverQuery
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBytes)).Decode(&queries); err != nil {
+		writeJSON(w, http.StatusBadRequest, &errorResponse{err.Error()})
+		return
+	}
+	if len(queries) > s.maxBatch {
+		writeJSON(w, http.StatusRequestEntityTooLarge, &errorResponse{fmt.Sprintf("batch has more than %d queries", s.maxBatch)})
+		return
+	}
+
+	var responses []interface{} = make([]interface{}, len(queries))
+	for i := range queries {
+		response, err := s.handle(&queries[i])
+		if err != nil {
+			response = &errorResponse{err.Error()}
+		}
+		responses[i] = response
+	}
+	writeJSON(w, http.StatusOK, responses)
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, op := range []string{"lookup", "prefixes", "complete", "topk", "rank", "unrank"} {
+		mux.HandleFunc("/"+op, s.handleQuery(op))
+	}
+	mux.HandleFunc("/batch", s.handleBatch)
+	return mux
+}
+
+func handleServe(args []string) {
+	var df dictFlags
+	var addr string
+	var maxLimit int
+	var maxBatch int
+	flags := newFlagSet("serve")
+	df.register(flags)
+	flags.StringVar(&addr, "addr", "localhost:8080", "address to listen on")
+	flags.IntVar(&maxLimit, "n", 100, "maximum number of completions in a response")
+	flags.IntVar(&maxBatch, "batch", 1000, "maximum number of queries in a batch")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return
+	}
+
+	f := loadDict(flags.Arg(0), &df)
+	log.Printf("listening on %s\n", addr)
+	log.Fatal(http.ListenAndServe(addr, newServer(f, maxLimit, maxBatch).handler()))
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/deNULL/dawg"
+)
+
+func newTestServer(t *testing.T, keys []string, maxBatch int) *server {
+	var lexicon strings.Builder
+	for i, key := range keys {
+		fmt.Fprintf(&lexicon, "%s\t%d\n", key, i)
+	}
+	options := &dawg.BuildOptions{Sort: true, Utfc: true, Guide: true, Index: true}
+	set, err := dawg.BuildFrom(strings.NewReader(lexicon.String()), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &dictFile{dict: set.Dictionary, guide: set.Guide, index: set.Index, utfc: true}
+	return newServer(f, 10, maxBatch)
+}
+
+// Sends requests from several goroutines, which must not share buffers of
+// encoding. Run with -race.
+func TestServerParallel(t *testing.T) {
+	var keys []string = []string{"кот", "кошка", "собака", "日本語", "apple", "apples", "tiếng việt"}
+	handler := newTestServer(t, keys, 100).handler()
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for n := 0; n < 50; n++ {
+				var i int = (worker + n) % len(keys)
+				request := httptest.NewRequest(http.MethodGet, "/lookup?key="+url.QueryEscape(keys[i]), nil)
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, request)
+
+				var response lookupResponse
+				if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+					t.Errorf("Key %q: %v", keys[i], err)
+					return
+				}
+				if !response.Found || response.Key != keys[i] || *response.Value != int64(i) {
+					t.Errorf("Key %q: unexpected response %s", keys[i], recorder.Body)
+					return
+				}
+
+				request = httptest.NewRequest(http.MethodGet, "/complete?prefix="+url.QueryEscape(string([]rune(keys[i])[:2])), nil)
+				recorder = httptest.NewRecorder()
+				handler.ServeHTTP(recorder, request)
+				if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), keys[i]) {
+					t.Errorf("Prefix of %q: unexpected response %s", keys[i], recorder.Body)
+					return
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+}
+
+func TestServerBatch(t *testing.T) {
+	handler := newTestServer(t, []string{"a", "b"}, 2).handler()
+	var tests = []struct {
+		body     string
+		status   int
+		expected string
+	}{
+		{`[{"op":"lookup","key":"b"},{"op":"rank","key":"a"}]`, http.StatusOK, `[{"key":"b","found":true,"value":1},{"key":"a","index":0}]`},
+		{`[{"op":"lookup"},{"op":"lookup"},{"op":"lookup"}]`, http.StatusRequestEntityTooLarge, ""},
+		{"[" + strings.Repeat(`{"op":"lookup"},`, maxBatchBytes/16) + "{}]", http.StatusBadRequest, ""},
+	}
+	for i, test := range tests {
+		request := httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(test.body))
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got %d", i, test.status, recorder.Code)
+		}
+		if test.expected != "" && strings.TrimSpace(recorder.Body.String()) != test.expected {
+			t.Errorf("Test %d: unexpected response %s", i, recorder.Body)
+		}
+	}
+}
+
+// Compares top completions found with aggregate pruning to those found by
+// walking all completions.
+func TestServerTopK(t *testing.T) {
+	var lexicon strings.Builder
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&lexicon, "k%d\t%d\n", i, (i*37)%101)
+	}
+	options := &dawg.BuildOptions{Sort: true, RankedGuide: true, Aggregates: true}
+	set, err := dawg.BuildFrom(strings.NewReader(lexicon.String()), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pruned := newServer(&dictFile{dict: set.Dictionary, rankedGuide: set.RankedGuide, aggregates: set.Aggregates}, 10, 1)
+	plain := newServer(&dictFile{dict: set.Dictionary, rankedGuide: set.RankedGuide}, 10, 1)
+	for _, prefix := range []string{"", "k", "k1", "k42", "k499", "x"} {
+		for _, limit := range []int{1, 3, 10} {
+			q := &serverQuery{Op: "topk", Prefix: prefix, Limit: limit}
+			expected, err := plain.complete(q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response, err := pruned.topk(q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(response) != fmt.Sprint(expected) {
+				t.Errorf("Prefix %q, limit %d: expected %v, got %v", prefix, limit, expected, response)
+			}
+		}
+	}
+}
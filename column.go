@@ -0,0 +1,311 @@
+package dawg
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// Type of values stored in a column.
+type ColumnType ucharType
+
+const (
+	Uint8Column ColumnType = iota + 1
+	Uint16Column
+	Uint32Column
+	Uint64Column
+	Int8Column
+	Int16Column
+	Int32Column
+	Int64Column
+	Float32Column
+	Float64Column
+	// Variable-length byte strings.
+	BlobColumn
+)
+
+// Number of bytes per value, zero for variable-length values.
+func (kind ColumnType) width() sizeType {
+	switch kind {
+	case Uint8Column, Int8Column:
+		return 1
+	case Uint16Column, Int16Column:
+		return 2
+	case Uint32Column, Int32Column, Float32Column:
+		return 4
+	case Uint64Column, Int64Column, Float64Column:
+		return 8
+	}
+	return 0
+}
+
+func (kind ColumnType) isSigned() bool {
+	return kind >= Int8Column && kind <= Int64Column
+}
+
+func (kind ColumnType) isFloat() bool {
+	return kind == Float32Column || kind == Float64Column
+}
+
+// Longest name of a column which is accepted on reading.
+const maxColumnNameLength = 1 << 16
+
+// Size of chunks in which lengths read from a file are allocated.
+const readChunkSize = 1 << 20
+
+// A named array of values, one per key, addressed by key indices.
+type Column struct {
+	name string
+	kind ColumnType
+	size sizeType
+
+	data  []byte
+	blobs [][]byte
+}
+
+func NewColumn(name string, kind ColumnType, size sizeType) *Column {
+	col := &Column{
+		name: name,
+		kind: kind,
+		size: size,
+	}
+	if kind == BlobColumn {
+		col.blobs = make([][]byte, size)
+	} else {
+		col.data = make([]byte, size*kind.width())
+	}
+	return col
+}
+
+func (col *Column) Name() string {
+	return col.name
+}
+
+func (col *Column) Type() ColumnType {
+	return col.kind
+}
+
+// Number of values.
+func (col *Column) Size() sizeType {
+	return col.size
+}
+
+// Reads raw bits of a fixed-width value.
+func (col *Column) Uint(i sizeType) uint64 {
+	var width sizeType = col.kind.width()
+	var buf []byte = col.data[i*width : (i+1)*width]
+	switch width {
+	case 1:
+		return uint64(buf[0])
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(buf))
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(buf))
+	}
+	return binary.LittleEndian.Uint64(buf)
+}
+
+// Writes raw bits of a fixed-width value, truncating them to its width.
+func (col *Column) SetUint(i sizeType, value uint64) {
+	var width sizeType = col.kind.width()
+	var buf []byte = col.data[i*width : (i+1)*width]
+	switch width {
+	case 1:
+		buf[0] = byte(value)
+	case 2:
+		binary.LittleEndian.PutUint16(buf, uint16(value))
+	case 4:
+		binary.LittleEndian.PutUint32(buf, uint32(value))
+	default:
+		binary.LittleEndian.PutUint64(buf, value)
+	}
+}
+
+// Reads a value as a signed integer.
+func (col *Column) Int(i sizeType) int64 {
+	if col.kind.isFloat() {
+		return int64(col.Float(i))
+	}
+	var value uint64 = col.Uint(i)
+	if col.kind.isSigned() {
+		// Extends the sign bit.
+		var shift uint = uint(64 - 8*col.kind.width())
+		return int64(value<<shift) >> shift
+	}
+	return int64(value)
+}
+
+func (col *Column) SetInt(i sizeType, value int64) {
+	if col.kind.isFloat() {
+		col.SetFloat(i, float64(value))
+		return
+	}
+	col.SetUint(i, uint64(value))
+}
+
+// Reads a value as a floating point number.
+func (col *Column) Float(i sizeType) float64 {
+	switch col.kind {
+	case Float32Column:
+		return float64(math.Float32frombits(uint32(col.Uint(i))))
+	case Float64Column:
+		return math.Float64frombits(col.Uint(i))
+	}
+	if col.kind.isSigned() {
+		return float64(col.Int(i))
+	}
+	return float64(col.Uint(i))
+}
+
+func (col *Column) SetFloat(i sizeType, value float64) {
+	switch col.kind {
+	case Float32Column:
+		col.SetUint(i, uint64(math.Float32bits(float32(value))))
+	case Float64Column:
+		col.SetUint(i, math.Float64bits(value))
+	default:
+		col.SetInt(i, int64(value))
+	}
+}
+
+// Reads a variable-length value. The result must not be modified.
+func (col *Column) Blob(i sizeType) []byte {
+	return col.blobs[i]
+}
+
+func (col *Column) SetBlob(i sizeType, value []byte) {
+	col.blobs[i] = append([]byte(nil), value...)
+}
+
+// Number of bytes occupied by values.
+func (col *Column) TotalSize() sizeType {
+	if col.kind != BlobColumn {
+		return len(col.data)
+	}
+	var size sizeType = 0
+	for _, blob := range col.blobs {
+		size += len(blob)
+	}
+	return size
+}
+
+func ReadColumn(r io.Reader) *Column {
+	col := &Column{}
+	if !col.Read(r) {
+		return nil
+	}
+	return col
+}
+
+// Reads a column from an input stream.
+func (col *Column) Read(r io.Reader) bool {
+	var header struct {
+		Kind       ColumnType
+		NameLength baseType
+	}
+	err := binary.Read(r, binary.LittleEndian, &header)
+	if err != nil || header.Kind < Uint8Column || header.Kind > BlobColumn ||
+		header.NameLength > maxColumnNameLength {
+		return false
+	}
+
+	name, ok := readBytes(r, sizeType(header.NameLength))
+	if !ok {
+		return false
+	}
+
+	var baseSize baseType
+	err = binary.Read(r, binary.LittleEndian, &baseSize)
+	if err != nil {
+		return false
+	}
+
+	col.name = string(name)
+	col.kind = header.Kind
+	col.size = sizeType(baseSize)
+	if col.kind != BlobColumn {
+		col.data, ok = readBytes(r, col.size*col.kind.width())
+		return ok
+	}
+
+	lengths, ok := readBytes(r, col.size*4)
+	if !ok {
+		return false
+	}
+
+	// All values share a single buffer.
+	var total sizeType = 0
+	for i := 0; i < col.size; i++ {
+		total += sizeType(binary.LittleEndian.Uint32(lengths[i*4:]))
+	}
+	data, ok := readBytes(r, total)
+	if !ok {
+		return false
+	}
+
+	col.blobs = make([][]byte, col.size)
+	for i := range col.blobs {
+		var length baseType = binary.LittleEndian.Uint32(lengths[i*4:])
+		col.blobs[i] = data[:length:length]
+		data = data[length:]
+	}
+	return true
+}
+
+// Reads a given number of bytes. The buffer grows in chunks as input arrives,
+// so a corrupt length fails at the end of input instead of being allocated.
+func readBytes(r io.Reader, n sizeType) ([]byte, bool) {
+	var data []byte = make([]byte, 0)
+	for len(data) < n {
+		var chunk sizeType = n - len(data)
+		if chunk > readChunkSize {
+			chunk = readChunkSize
+		}
+		data = append(data, make([]byte, chunk)...)
+		if _, err := io.ReadFull(r, data[len(data)-chunk:]); err != nil {
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+// Writes a column to an output stream.
+func (col *Column) Write(w io.Writer) bool {
+	err := binary.Write(w, binary.LittleEndian, col.kind)
+	if err != nil {
+		return false
+	}
+	err = binary.Write(w, binary.LittleEndian, baseType(len(col.name)))
+	if err != nil {
+		return false
+	}
+	_, err = io.WriteString(w, col.name)
+	if err != nil {
+		return false
+	}
+	err = binary.Write(w, binary.LittleEndian, baseType(col.size))
+	if err != nil {
+		return false
+	}
+
+	if col.kind != BlobColumn {
+		_, err = w.Write(col.data)
+		return err == nil
+	}
+
+	var lengths = make([]baseType, col.size)
+	for i, blob := range col.blobs {
+		lengths[i] = baseType(len(blob))
+	}
+	err = binary.Write(w, binary.LittleEndian, lengths)
+	if err != nil {
+		return false
+	}
+	for _, blob := range col.blobs {
+		_, err = w.Write(blob)
+		if err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -1,19 +1,24 @@
 package dawg
 
+import "strings"
+
 type SomeCompleter interface {
 	Start(baseType)
 	Next() bool
 	Key() string
 	Value() valueType
+	Value64() int64
 	Length() sizeType
 }
 type Completer struct {
 	dict  *Dictionary
-	guide *Guide
+	guide SomeGuide
 
 	path       []ucharType
 	indexStack []baseType
 	lastIndex  baseType
+	// Whether Next() moves on from lastIndex instead of starting there.
+	hasLast bool
 }
 
 func NewCompleter(dict *Dictionary, guide *Guide) *Completer {
@@ -33,6 +38,9 @@ func (c *Completer) Key() string {
 func (c *Completer) Value() valueType {
 	return c.dict.Value(c.lastIndex)
 }
+func (c *Completer) Value64() int64 {
+	return c.dict.Value64(c.lastIndex)
+}
 
 // Starts completing keys from given index and prefix.
 func (c *Completer) Start(index baseType) {
@@ -49,10 +57,10 @@ func (c *Completer) StartStringLen(index baseType, prefix string, length sizeTyp
 	c.path[length] = 0
 
 	c.indexStack = c.indexStack[:0]
-	if c.guide.size != 0 {
+	if c.guide.Size() != 0 {
 		c.indexStack = append(c.indexStack, index)
-		c.lastIndex = c.dict.Root()
 	}
+	c.hasLast = false
 }
 
 // Gets the next key.
@@ -62,36 +70,15 @@ func (c *Completer) Next() bool {
 	}
 	var index baseType = c.indexStack[len(c.indexStack)-1]
 
-	if c.lastIndex != c.dict.Root() {
+	if c.hasLast {
 		var childLabel ucharType = c.guide.Child(index)
 		if childLabel != 0 {
 			// Follows a transition to the first child.
 			if !c.Follow(childLabel, &index) {
 				return false
 			}
-		} else {
-			for {
-				var siblingLabel ucharType = c.guide.Sibling(index)
-
-				// Moves to the previous node
-				if len(c.path) > 1 {
-					c.path = c.path[:len(c.path)-1]
-					c.path[len(c.path)-1] = 0
-				}
-				c.indexStack = c.indexStack[:len(c.indexStack)-1]
-				if len(c.indexStack) == 0 {
-					return false
-				}
-
-				index = c.indexStack[len(c.indexStack)-1]
-				if siblingLabel != 0 {
-					// Follows a transition to the next sibling.
-					if !c.Follow(siblingLabel, &index) {
-						return false
-					}
-					break
-				}
-			}
+		} else if !c.followSibling(&index) {
+			return false
 		}
 	}
 
@@ -99,6 +86,191 @@ func (c *Completer) Next() bool {
 	return c.FindTerminal(index)
 }
 
+// Starts completing keys from given index and prefix, skipping all keys
+// preceding a given one, which does not need to exist. Seeking takes time
+// proportional to the length of the key.
+func (c *Completer) StartFrom(index baseType, prefix string, key string) {
+	c.seek(index, prefix, key, false)
+}
+
+// Starts completing keys from given index and prefix, skipping all keys up to
+// and including lastKey, which does not need to exist. Passing Key() of the
+// last completed key allows to resume completion later, e.g. to paginate.
+func (c *Completer) StartAfter(index baseType, prefix string, lastKey string) {
+	c.seek(index, prefix, lastKey, true)
+}
+
+// Positions the completer before the first key not less than a given one.
+func (c *Completer) Seek(key string) {
+	c.StartFrom(c.dict.Root(), "", key)
+}
+
+// Calls fn for keys from lo (inclusive) to hi (exclusive) in ascending order
+// until it returns false. The key passed to fn is valid only during the call.
+func (c *Completer) Range(lo string, hi string, fn func(key []byte, value int64) bool) {
+	c.Seek(lo)
+	for c.Next() {
+		var key []ucharType = c.path[:c.Length()]
+		if string(key) >= hi || !fn(key, c.Value64()) {
+			break
+		}
+	}
+}
+
+// Finds the first key greater than a given one.
+func (c *Completer) Successor(key string) (string, int64, bool) {
+	c.StartAfter(c.dict.Root(), "", key)
+	if !c.Next() {
+		return "", 0, false
+	}
+	return string(c.path[:c.Length()]), c.Value64(), true
+}
+
+// Finds the last key less than a given one. Descends along the key once and
+// remembers the deepest branch to the left of it.
+func (c *Completer) Predecessor(key string) (string, int64, bool) {
+	var index baseType = c.dict.Root()
+	var branchIndex baseType
+	var branchDepth sizeType = -1
+	var branchLabel ucharType
+	for i := 0; i < len(key); i++ {
+		// Proper prefixes of the key precede it.
+		if c.dict.HasValue(index) {
+			branchIndex, branchDepth, branchLabel = index, i, 0
+		}
+		if key[i] == 0 {
+			break
+		}
+
+		// Finds the last child preceding the label.
+		var lastLabel ucharType = 0
+		var childLabel ucharType = c.guide.Child(index)
+		for childLabel != 0 && childLabel < key[i] {
+			lastLabel = childLabel
+			var childIndex baseType = index
+			if !c.dict.Follow(childLabel, &childIndex) {
+				return "", 0, false
+			}
+			childLabel = c.guide.Sibling(childIndex)
+		}
+		if lastLabel != 0 {
+			branchIndex, branchDepth, branchLabel = index, i, lastLabel
+		}
+
+		if !c.dict.Follow(key[i], &index) {
+			break
+		}
+	}
+	if branchDepth < 0 {
+		return "", 0, false
+	}
+
+	// Finds the last key in the branch.
+	var path []ucharType = []ucharType(key[:branchDepth])
+	index = branchIndex
+	for label := branchLabel; label != 0; {
+		if !c.dict.Follow(label, &index) {
+			return "", 0, false
+		}
+		path = append(path, label)
+
+		label = 0
+		for childLabel := c.guide.Child(index); childLabel != 0; {
+			label = childLabel
+			var childIndex baseType = index
+			if !c.dict.Follow(childLabel, &childIndex) {
+				return "", 0, false
+			}
+			childLabel = c.guide.Sibling(childIndex)
+		}
+	}
+	if !c.dict.HasValue(index) {
+		return "", 0, false
+	}
+	return string(path), c.dict.Value64(index), true
+}
+
+// Positions the completer before the first key following a given one (or
+// equal to it, if skipEqual is false).
+func (c *Completer) seek(index baseType, prefix string, key string, skipEqual bool) {
+	c.StartString(index, prefix)
+	if len(c.indexStack) == 0 {
+		return
+	}
+
+	if !strings.HasPrefix(key, prefix) {
+		// Either all keys with the prefix follow the key or none of them.
+		if key > prefix {
+			c.indexStack = c.indexStack[:0]
+		}
+		return
+	}
+
+	for i := len(prefix); i < len(key); i++ {
+		var label ucharType = key[i]
+		if label == 0 {
+			// Keys never contain zeros, so only extensions of the current
+			// node follow the key. It also handles a terminating zero of Key().
+			c.lastIndex = index
+			c.hasLast = true
+			return
+		}
+		if c.Follow(label, &index) {
+			continue
+		}
+
+		// Finds the first child following the label.
+		var childLabel ucharType = c.guide.Child(index)
+		for childLabel != 0 && childLabel < label {
+			var childIndex baseType = index
+			if !c.dict.Follow(childLabel, &childIndex) {
+				c.indexStack = c.indexStack[:0]
+				return
+			}
+			childLabel = c.guide.Sibling(childIndex)
+		}
+
+		// Next() will find a terminal starting from the current node.
+		if childLabel != 0 {
+			if !c.Follow(childLabel, &index) {
+				c.indexStack = c.indexStack[:0]
+			}
+		} else if !c.followSibling(&index) {
+			c.indexStack = c.indexStack[:0]
+		}
+		return
+	}
+
+	// Next() will move past the node of the key.
+	if skipEqual {
+		c.lastIndex = index
+		c.hasLast = true
+	}
+}
+
+// Moves to the next sibling of the current node or of its nearest ancestor.
+func (c *Completer) followSibling(index *baseType) bool {
+	for {
+		var siblingLabel ucharType = c.guide.Sibling(*index)
+
+		// Moves to the previous node
+		if len(c.path) > 1 {
+			c.path = c.path[:len(c.path)-1]
+			c.path[len(c.path)-1] = 0
+		}
+		c.indexStack = c.indexStack[:len(c.indexStack)-1]
+		if len(c.indexStack) == 0 {
+			return false
+		}
+
+		*index = c.indexStack[len(c.indexStack)-1]
+		if siblingLabel != 0 {
+			// Follows a transition to the next sibling.
+			return c.Follow(siblingLabel, index)
+		}
+	}
+}
+
 // Follows a transition.
 func (c *Completer) Follow(label ucharType, index *baseType) bool {
 	if !c.dict.Follow(label, index) {
@@ -125,5 +297,6 @@ func (c *Completer) FindTerminal(index baseType) bool {
 	}
 
 	c.lastIndex = index
+	c.hasLast = true
 	return true
 }
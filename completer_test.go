@@ -0,0 +1,172 @@
+package dawg
+
+import (
+	"sort"
+	"testing"
+)
+
+func sortedTestKeys(lexicon map[string]valueType) []string {
+	keys := make([]string, 0, len(lexicon))
+	for key := range lexicon {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestCompleterStartAfter(t *testing.T) {
+	dawg, dict, lexicon := buildTestLexicon(t)
+	guide := BuildGuide(dawg, dict)
+	keys := sortedTestKeys(lexicon)
+	completer := NewCompleter(dict, guide)
+
+	for _, prefix := range []string{"", "b", "bin", "c"} {
+		var index baseType = dict.Root()
+		if !dict.FollowString(prefix, &index) {
+			t.Fatalf("Prefix %q is not found", prefix)
+		}
+
+		for _, lastKey := range []string{"", "a", "ap", "apq", "b", "bin", "binc", "binder", "bz", "ca", "cat", "d", "bin\x00"} {
+			var expected []string
+			for _, key := range keys {
+				if len(key) >= len(prefix) && key[:len(prefix)] == prefix && key > lastKey {
+					expected = append(expected, key)
+				}
+			}
+
+			completer.StartAfter(index, prefix, lastKey)
+			var actual []string
+			for completer.Next() {
+				actual = append(actual, completer.Key()[:completer.Length()])
+			}
+			if len(actual) != len(expected) {
+				t.Errorf("StartAfter(%q, %q) completed %q, expected %q", prefix, lastKey, actual, expected)
+				continue
+			}
+			for i := range actual {
+				if actual[i] != expected[i] {
+					t.Errorf("StartAfter(%q, %q) completed %q, expected %q", prefix, lastKey, actual, expected)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestReverseCompleter(t *testing.T) {
+	_, dict, lexicon := buildTestLexicon(t)
+	keys := sortedTestKeys(lexicon)
+	completer := NewReverseCompleter(dict)
+
+	for _, prefix := range []string{"", "a", "bin", "cat"} {
+		var index baseType = dict.Root()
+		if !dict.FollowString(prefix, &index) {
+			t.Fatalf("Prefix %q is not found", prefix)
+		}
+
+		var expected []string
+		for i := len(keys) - 1; i >= 0; i-- {
+			if len(keys[i]) >= len(prefix) && keys[i][:len(prefix)] == prefix {
+				expected = append(expected, keys[i])
+			}
+		}
+
+		completer.StartString(index, prefix)
+		var actual []string
+		for completer.Next() {
+			var key string = completer.Key()
+			if len(key) != completer.Length()+1 || key[completer.Length()] != 0 {
+				t.Fatalf("Key %q is not terminated by zero", key)
+			}
+			key = key[:completer.Length()]
+			if completer.Value() != lexicon[key] {
+				t.Errorf("Key %q has value %d", key, completer.Value())
+			}
+			actual = append(actual, key)
+		}
+		if len(actual) != len(expected) {
+			t.Fatalf("Prefix %q completed %q, expected %q", prefix, actual, expected)
+		}
+		for i := range actual {
+			if actual[i] != expected[i] {
+				t.Fatalf("Prefix %q completed %q, expected %q", prefix, actual, expected)
+			}
+		}
+	}
+}
+
+func TestCompleterSeek(t *testing.T) {
+	dawg, dict, lexicon := buildTestLexicon(t)
+	keys := sortedTestKeys(lexicon)
+	completer := NewCompleter(dict, BuildGuide(dawg, dict))
+
+	for _, query := range []string{"", "a", "ap", "b", "bin", "binda", "bz", "cat", "d"} {
+		i := sort.SearchStrings(keys, query)
+
+		completer.Seek(query)
+		if completer.Next() != (i < len(keys)) || (i < len(keys) && completer.Key()[:completer.Length()] != keys[i]) {
+			t.Errorf("Seek(%q) does not find %d-th key", query, i)
+		}
+
+		key, _, ok := completer.Predecessor(query)
+		if ok != (i > 0) || (ok && key != keys[i-1]) {
+			t.Errorf("Predecessor(%q) = %q", query, key)
+		}
+
+		j := i
+		if j < len(keys) && keys[j] == query {
+			j++
+		}
+		key, _, ok = completer.Successor(query)
+		if ok != (j < len(keys)) || (ok && key != keys[j]) {
+			t.Errorf("Successor(%q) = %q", query, key)
+		}
+	}
+
+	var actual []string
+	completer.Range("an", "bind", func(key []byte, value int64) bool {
+		actual = append(actual, string(key))
+		return true
+	})
+	expected := []string{"an", "and", "appear", "apple", "bin", "binary"}
+	if len(actual) != len(expected) {
+		t.Fatalf("Range completed %q, expected %q", actual, expected)
+	}
+	for i := range actual {
+		if actual[i] != expected[i] {
+			t.Fatalf("Range completed %q, expected %q", actual, expected)
+		}
+	}
+}
+
+func TestCompleterEmptyKey(t *testing.T) {
+	dawg, dict, lexicon := buildTestLexiconWithEmptyKey(t)
+	keys := sortedTestKeys(lexicon)
+	completer := NewCompleter(dict, BuildGuide(dawg, dict))
+
+	completer.Start(dict.Root())
+	var count int = 0
+	for ; count <= len(keys) && completer.Next(); count++ {
+		if key := completer.Key()[:completer.Length()]; key != keys[count] {
+			t.Fatalf("Completed %q, expected %q", key, keys[count])
+		}
+	}
+	if count != len(keys) {
+		t.Errorf("Completed %d keys, expected %d", count, len(keys))
+	}
+
+	completer.StartAfter(dict.Root(), "", "")
+	if !completer.Next() || completer.Key()[:completer.Length()] != keys[1] {
+		t.Errorf("StartAfter does not skip the empty key")
+	}
+	if key, _, ok := completer.Successor(""); !ok || key != keys[1] {
+		t.Errorf("Successor(\"\") = %q", key)
+	}
+	if key, value, ok := completer.Predecessor(keys[1]); !ok || key != "" || value != int64(lexicon[""]) {
+		t.Errorf("Predecessor(%q) = %q", keys[1], key)
+	}
+	completer.Seek("")
+	if !completer.Next() || completer.Length() != 0 || completer.Value() != lexicon[""] {
+		t.Errorf("Seek(\"\") does not find the empty key")
+	}
+}
@@ -4,10 +4,12 @@ type Dawg struct {
 	basePool               []BaseUnit
 	labelPool              []ucharType
 	flagPool               *BitPool
+	values                 []int64
 	numOfStates            sizeType
 	numOfMergedTransitions sizeType
 	numOfMergedStates      sizeType
 	numOfMergingStates     sizeType
+	numOfDuplicates        sizeType
 }
 
 func NewDawg() *Dawg {
@@ -37,6 +39,11 @@ func (dawg *Dawg) NumOfMergingStates() sizeType {
 	return dawg.numOfMergingStates
 }
 
+// Number of keys which were inserted again.
+func (dawg *Dawg) NumOfDuplicates() sizeType {
+	return dawg.numOfDuplicates
+}
+
 // Number of merged states.
 func (dawg *Dawg) NumOfMergedStates() sizeType {
 	return dawg.numOfMergedStates
@@ -71,6 +78,14 @@ func (dawg *Dawg) Value(index baseType) valueType {
 	return dawg.basePool[index].value()
 }
 
+// Reads a value, looking it up in a value table if there is one.
+func (dawg *Dawg) Value64(index baseType) int64 {
+	if dawg.values != nil {
+		return dawg.values[dawg.Value(index)]
+	}
+	return int64(dawg.Value(index))
+}
+
 func (dawg *Dawg) IsLeaf(index baseType) bool {
 	return dawg.Label(index) == 0
 }
@@ -86,6 +101,8 @@ func (dawg *Dawg) Clear() {
 	dawg.basePool = dawg.basePool[:0]
 	dawg.labelPool = dawg.labelPool[:0]
 	dawg.flagPool.clear()
+	dawg.values = nil
 	dawg.numOfStates = 0
 	dawg.numOfMergedStates = 0
+	dawg.numOfDuplicates = 0
 }
@@ -1,5 +1,17 @@
 package dawg
 
+import (
+	"errors"
+	"sort"
+)
+
+// Error returned when a key is inserted out of order.
+var ErrKeyOrder = errors.New("keys are not sorted")
+
+// Error returned when a value is negative or does not fit into 31 bits, and
+// a builder does not use a value table.
+var ErrValueRange = errors.New("value is out of range without a value table")
+
 const defaultInitialHashTableSize = 1 << 8
 
 type DawgBuilder struct {
@@ -11,6 +23,14 @@ type DawgBuilder struct {
 	hashTable              []baseType
 	unfixedUnits           []baseType
 	unusedUnits            []baseType
+	values                 []int64
+	valueIds               map[int64]valueType
+	valueTable             bool
+	duplicatePolicy        DuplicatePolicy
+	observer               BuildObserver
+	numOfKeys              sizeType
+	numOfDuplicates        sizeType
+	numOfHashTableResizes  sizeType
 	numOfStates            sizeType
 	numOfMergedTransitions sizeType
 	numOfMergingStates     sizeType
@@ -76,6 +96,34 @@ func (db *DawgBuilder) init() {
 	db.allocateTransition()
 	db.unitPool[0].label = 0xff
 	db.unfixedUnits = append(db.unfixedUnits, 0)
+
+	if db.observer != nil {
+		db.observer.OnPhaseStart(DawgPhase)
+	}
+}
+
+// Sets an observer which receives progress of building.
+func (db *DawgBuilder) SetObserver(observer BuildObserver) {
+	db.observer = observer
+}
+
+func (db *DawgBuilder) stats() *BuildStats {
+	return &BuildStats{
+		Phase:                  DawgPhase,
+		NumOfKeys:              db.numOfKeys,
+		NumOfStates:            db.numOfStates,
+		NumOfMergedTransitions: db.numOfMergedTransitions,
+		NumOfHashTableResizes:  db.numOfHashTableResizes,
+		HashTableSize:          len(db.hashTable),
+	}
+}
+
+// Counts an inserted key and reports progress periodically.
+func (db *DawgBuilder) countKey() {
+	db.numOfKeys += 1
+	if db.observer != nil && db.numOfKeys%progressInterval == 0 {
+		db.observer.OnProgress(db.stats())
+	}
 }
 
 // 32-bit mix function.
@@ -176,7 +224,16 @@ func (db *DawgBuilder) findUnit(unitIndex baseType, hashId *baseType) baseType {
 
 // Expands a hash table.
 func (db *DawgBuilder) expandHashTable() {
-	var hashTableSize sizeType = len(db.hashTable) << 1
+	db.rebuildHashTable(len(db.hashTable) << 1)
+
+	db.numOfHashTableResizes += 1
+	if db.observer != nil {
+		db.observer.OnProgress(db.stats())
+	}
+}
+
+// Rebuilds a hash table from fixed transitions.
+func (db *DawgBuilder) rebuildHashTable(hashTableSize sizeType) {
 	db.hashTable = make([]baseType, hashTableSize)
 
 	// Builds a new hash table.
@@ -199,51 +256,62 @@ func (db *DawgBuilder) fixUnits(index baseType) {
 		var unfixedIndex baseType = db.unfixedUnits[len(db.unfixedUnits)-1]
 		db.unfixedUnits = db.unfixedUnits[:len(db.unfixedUnits)-1]
 
-		if db.numOfStates >= len(db.hashTable)-(len(db.hashTable)>>2) {
-			db.expandHashTable()
-		}
+		var matchedIndex baseType = db.fixUnit(unfixedIndex)
+		db.unitPool[db.unfixedUnits[len(db.unfixedUnits)-1]].child = matchedIndex
+	}
+	db.unfixedUnits = db.unfixedUnits[:len(db.unfixedUnits)-1]
+}
 
-		var numOfSiblings sizeType = 0
-		for i := unfixedIndex; i != 0; i = db.unitPool[i].sibling {
-			numOfSiblings += 1
-		}
+// Fixes a unit and its siblings into a state, or merges them into an
+// equivalent state. Returns an index of the state.
+func (db *DawgBuilder) fixUnit(unfixedIndex baseType) baseType {
+	if db.numOfStates >= len(db.hashTable)-(len(db.hashTable)>>2) {
+		db.expandHashTable()
+	}
 
-		var hashId baseType
-		var matchedIndex baseType = db.findUnit(unfixedIndex, &hashId)
-		if matchedIndex != 0 {
-			db.numOfMergedTransitions += numOfSiblings
+	var numOfSiblings sizeType = 0
+	for i := unfixedIndex; i != 0; i = db.unitPool[i].sibling {
+		numOfSiblings += 1
+	}
 
-			// Records a merging state.
-			if !db.flagPool.get(matchedIndex) {
-				db.numOfMergingStates += 1
-				db.flagPool.set(matchedIndex, true)
-			}
-		} else {
-			// Fixes units into pairs of base values and labels.
-			var transitionIndex baseType = 0
-			for i := 0; i < numOfSiblings; i += 1 {
-				transitionIndex = db.allocateTransition()
-			}
-			for i := unfixedIndex; i != 0; i = db.unitPool[i].sibling {
-				db.basePool[transitionIndex] = BaseUnit(db.unitPool[i].base())
-				db.labelPool[transitionIndex] = db.unitPool[i].label
-				transitionIndex -= 1
-			}
-			matchedIndex = transitionIndex + 1
-			db.hashTable[hashId] = matchedIndex
-			db.numOfStates += 1
-		}
+	var hashId baseType
+	var matchedIndex baseType = db.findUnit(unfixedIndex, &hashId)
+	if matchedIndex != 0 {
+		db.numOfMergedTransitions += numOfSiblings
 
-		// Deletes fixed units.
-		var next baseType
-		for current := unfixedIndex; current != 0; current = next {
-			next = db.unitPool[current].sibling
-			db.freeUnit(current)
+		// Records a merging state.
+		db.setMerging(matchedIndex)
+	} else {
+		// Fixes units into pairs of base values and labels.
+		var transitionIndex baseType = 0
+		for i := 0; i < numOfSiblings; i += 1 {
+			transitionIndex = db.allocateTransition()
 		}
+		for i := unfixedIndex; i != 0; i = db.unitPool[i].sibling {
+			db.basePool[transitionIndex] = BaseUnit(db.unitPool[i].base())
+			db.labelPool[transitionIndex] = db.unitPool[i].label
+			transitionIndex -= 1
+		}
+		matchedIndex = transitionIndex + 1
+		db.hashTable[hashId] = matchedIndex
+		db.numOfStates += 1
+	}
 
-		db.unitPool[db.unfixedUnits[len(db.unfixedUnits)-1]].child = matchedIndex
+	// Deletes fixed units.
+	var next baseType
+	for current := unfixedIndex; current != 0; current = next {
+		next = db.unitPool[current].sibling
+		db.freeUnit(current)
+	}
+	return matchedIndex
+}
+
+// Records a merging state.
+func (db *DawgBuilder) setMerging(index baseType) {
+	if !db.flagPool.get(index) {
+		db.numOfMergingStates += 1
+		db.flagPool.set(index, true)
 	}
-	db.unfixedUnits = db.unfixedUnits[:len(db.unfixedUnits)-1]
 }
 
 func (db *DawgBuilder) clear() {
@@ -255,13 +323,165 @@ func (db *DawgBuilder) clear() {
 	db.hashTable = []baseType{0}
 	db.unfixedUnits = db.unfixedUnits[:0]
 	db.unusedUnits = db.unusedUnits[:0]
+	db.values = nil
+	db.valueIds = nil
 
+	db.numOfKeys = 0
+	db.numOfDuplicates = 0
+	db.numOfHashTableResizes = 0
 	db.numOfStates = 1
 	db.numOfMergedTransitions = 0
 	db.numOfMergingStates = 0
 }
 
+// Gets an id of a value from a value table.
+func (db *DawgBuilder) valueId(value int64) valueType {
+	id, ok := db.valueIds[value]
+	if !ok {
+		id = valueType(len(db.values))
+		db.values = append(db.values, value)
+		db.valueIds[value] = id
+	}
+	return id
+}
+
+// Makes the builder keep values in a value table, so that 64-bit and negative
+// values can be inserted. Dictionaries built with a value table keep ids of
+// values in units, so their values must be read with Value64.
+func (db *DawgBuilder) UseValueTable() {
+	db.valueTable = true
+}
+
+// Moves values of inserted keys into a value table.
+func (db *DawgBuilder) moveValuesToTable() {
+	db.values = []int64{}
+	db.valueIds = map[int64]valueType{}
+	if len(db.hashTable) == 0 {
+		return
+	}
+
+	// Values of keys which are not fixed yet.
+	for _, unfixedIndex := range db.unfixedUnits {
+		for i := unfixedIndex; i != 0; i = db.unitPool[i].sibling {
+			if db.unitPool[i].label == 0 {
+				db.unitPool[i].setValue(db.valueId(int64(db.unitPool[i].value())))
+			}
+		}
+	}
+
+	// Values of fixed keys change hash values of their states.
+	for i := 1; i < len(db.basePool); i++ {
+		if db.labelPool[i] == 0 {
+			db.basePool[i] = BaseUnit(db.valueId(int64(db.basePool[i].value()))<<1) | (db.basePool[i] & 1)
+		}
+	}
+	db.rebuildHashTable(len(db.hashTable))
+}
+
+// Renumbers values in a value table in ascending order, so that ids of
+// values are compared in the same way as values themselves. Values which
+// are not used any longer are dropped.
+func (db *DawgBuilder) sortValueTable() {
+	var ids []valueType
+	var used []bool = make([]bool, len(db.values))
+	for i := 1; i < len(db.basePool); i++ {
+		if db.labelPool[i] == 0 {
+			var id valueType = db.basePool[i].value()
+			if !used[id] {
+				used[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Slice(ids, func(i int, j int) bool {
+		return db.values[ids[i]] < db.values[ids[j]]
+	})
+
+	var newIds []valueType = make([]valueType, len(db.values))
+	var values []int64 = make([]int64, len(ids))
+	for i, id := range ids {
+		newIds[id] = valueType(i)
+		values[i] = db.values[id]
+	}
+	for i := 1; i < len(db.basePool); i++ {
+		if db.labelPool[i] == 0 {
+			db.basePool[i] = BaseUnit(newIds[db.basePool[i].value()]<<1) | (db.basePool[i] & 1)
+		}
+	}
+	db.values = values
+}
+
+// Gets a value which is kept in a unit or referenced from it.
+func (db *DawgBuilder) tableValue(value valueType) int64 {
+	if db.values != nil {
+		return db.values[value]
+	}
+	return int64(value)
+}
+
+// Sets a 64-bit value to a leaf unit.
+func (db *DawgBuilder) setValue64(index baseType, value int64) error {
+	if db.values == nil {
+		if !db.valueTable {
+			if value < 0 || value > MaxValue {
+				return ErrValueRange
+			}
+			db.unitPool[index].setValue(valueType(value))
+			return nil
+		}
+		db.moveValuesToTable()
+	}
+	db.unitPool[index].setValue(db.valueId(value))
+	return nil
+}
+
+// Sets what to do when the same key is inserted again. The last value is
+// kept by default.
+func (db *DawgBuilder) SetDuplicatePolicy(policy DuplicatePolicy) {
+	db.duplicatePolicy = policy
+}
+
+// Number of keys inserted again.
+func (db *DawgBuilder) NumOfDuplicates() sizeType {
+	return db.numOfDuplicates
+}
+
+// Inserts a key with a 64-bit value. Returns ErrKeyOrder if keys are not
+// sorted, a DuplicateKeyError if the key is inserted again and the policy
+// does not allow it, or ErrValueRange if the value does not fit into a unit
+// and UseValueTable is not called.
+func (db *DawgBuilder) Insert(key []ucharType, value int64) error {
+	if db.values == nil {
+		if !db.valueTable {
+			if value < 0 || value > MaxValue {
+				return ErrValueRange
+			}
+			return db.insertKeyValue(key, len(key), valueType(value))
+		}
+		db.moveValuesToTable()
+	}
+	return db.insertKeyValue(key, len(key), db.valueId(value))
+}
+
+// Inserts a key with a 64-bit value. Values which do not fit into 31 bits
+// require a value table, see UseValueTable. Ids of values in the table are
+// ordered in the same way as values, so that ranked guides work as usual.
+func (db *DawgBuilder) InsertKeyValue64(key []ucharType, length sizeType, value int64) bool {
+	return db.Insert(key[:length], value) == nil
+}
+
+func (db *DawgBuilder) InsertStringValue64(key string, value int64) bool {
+	return db.InsertKeyValue64([]ucharType(key), len(key), value)
+}
+
 func (db *DawgBuilder) InsertKeyValue(key []ucharType, length sizeType, value valueType) bool {
+	if db.values != nil {
+		return db.insertKeyValue(key, length, db.valueId(int64(value))) == nil
+	}
+	return db.insertKeyValue(key, length, value) == nil
+}
+
+func (db *DawgBuilder) insertKeyValue(key []ucharType, length sizeType, value valueType) error {
 	// Initializes a builder if not initialized.
 	if len(db.hashTable) == 0 {
 		db.init()
@@ -285,7 +505,7 @@ func (db *DawgBuilder) InsertKeyValue(key []ucharType, length sizeType, value va
 
 		// Checks the order of keys.
 		if keyLabel < unitLabel {
-			return false
+			return ErrKeyOrder
 		} else if keyLabel > unitLabel {
 			db.unitPool[childIndex].hasSibling = true
 			db.fixUnits(childIndex)
@@ -296,6 +516,20 @@ func (db *DawgBuilder) InsertKeyValue(key []ucharType, length sizeType, value va
 		keyPos += 1
 	}
 
+	// Handles a key which is inserted again.
+	if keyPos > length {
+		db.numOfDuplicates += 1
+		if db.duplicatePolicy == DuplicateError {
+			return &DuplicateKeyError{Key: string(key[:length])}
+		}
+		var oldValue int64 = db.tableValue(db.unitPool[index].value())
+		if err := db.setValue64(index, db.duplicatePolicy.merge(oldValue, db.tableValue(value))); err != nil {
+			return err
+		}
+		db.countKey()
+		return nil
+	}
+
 	// Adds new units.
 	for keyPos <= length {
 		var keyLabel ucharType = 0
@@ -316,7 +550,8 @@ func (db *DawgBuilder) InsertKeyValue(key []ucharType, length sizeType, value va
 		keyPos += 1
 	}
 	db.unitPool[index].setValue(value)
-	return true
+	db.countKey()
+	return nil
 }
 
 func (db *DawgBuilder) InsertStringValue(key string, value valueType) bool {
@@ -337,15 +572,28 @@ func (db *DawgBuilder) Finish(dawg *Dawg) {
 	db.fixUnits(0)
 	db.basePool[0] = BaseUnit(db.unitPool[0].base())
 	db.labelPool[0] = db.unitPool[0].label
+	db.finishDawg(dawg)
+}
+
+// Moves fixed transitions into a dawg, and clears the builder.
+func (db *DawgBuilder) finishDawg(dawg *Dawg) {
+	if db.values != nil {
+		db.sortValueTable()
+	}
 
 	dawg.numOfStates = db.numOfStates
 	dawg.numOfMergedTransitions = db.numOfMergedTransitions
 	dawg.numOfMergedStates = db.numOfMergedStates()
 	dawg.numOfMergingStates = db.numOfMergingStates
+	dawg.numOfDuplicates = db.numOfDuplicates
+	if db.observer != nil {
+		db.observer.OnPhaseEnd(db.stats())
+	}
 
 	dawg.basePool, db.basePool = db.basePool, dawg.basePool
 	dawg.labelPool, db.labelPool = db.labelPool, dawg.labelPool
 	dawg.flagPool, db.flagPool = db.flagPool, dawg.flagPool
+	dawg.values = db.values
 
 	db.clear()
 }
@@ -0,0 +1,418 @@
+package dawg
+
+import "sync"
+
+// A transition of a state which is being fixed. A child is a number of a
+// state, or a value if the label is 0. A height and a hash of the child are
+// kept with it, so that a hash of the state can be calculated.
+type dawgTransition struct {
+	label  ucharType
+	child  baseType
+	height baseType
+	hash   uint64
+}
+
+// States in the order of fixing. Transitions of a state are kept in the
+// same order as in a dawg, and children are numbers of states.
+//
+// Heights and hashes do not depend on numbers of states, so that states of
+// different shards are equivalent only if their heights and hashes match.
+type dawgStates struct {
+	begins   []baseType
+	labels   []ucharType
+	children []baseType
+	heights  []baseType
+	hashes   []uint64
+	merging  []bool
+}
+
+func newDawgStates() *dawgStates {
+	return &dawgStates{begins: []baseType{0}}
+}
+
+func (states *dawgStates) size() sizeType {
+	return len(states.begins) - 1
+}
+
+// Makes a transition to a state or to a value.
+func (states *dawgStates) transition(label ucharType, child baseType) dawgTransition {
+	if label == 0 {
+		return dawgTransition{child: child}
+	}
+	return dawgTransition{label: label, child: child, height: states.heights[child], hash: states.hashes[child]}
+}
+
+// Appends a state and returns a transition to it without a label.
+func (states *dawgStates) add(transitions []dawgTransition, merging bool) dawgTransition {
+	var state dawgTransition = dawgTransition{child: baseType(states.size())}
+	state.hash = uint64(len(transitions))
+	for _, transition := range transitions {
+		var childHash uint64 = uint64(transition.child)
+		if transition.label != 0 {
+			childHash = transition.hash
+			if transition.height >= state.height {
+				state.height = transition.height + 1
+			}
+		}
+		state.hash = mixHash(state.hash ^ uint64(transition.label))
+		state.hash = mixHash(state.hash ^ childHash)
+
+		states.labels = append(states.labels, transition.label)
+		states.children = append(states.children, transition.child)
+	}
+	states.begins = append(states.begins, baseType(len(states.labels)))
+	states.heights = append(states.heights, state.height)
+	states.hashes = append(states.hashes, state.hash)
+	states.merging = append(states.merging, merging)
+	return state
+}
+
+// Recalculates heights and hashes after values are changed.
+func (states *dawgStates) rehash() {
+	var rehashed *dawgStates = newDawgStates()
+	var transitions []dawgTransition
+	for i := 0; i < states.size(); i++ {
+		transitions = transitions[:0]
+		for j := states.begins[i]; j < states.begins[i+1]; j++ {
+			transitions = append(transitions, rehashed.transition(states.labels[j], states.children[j]))
+		}
+		rehashed.add(transitions, states.merging[i])
+	}
+	states.heights = rehashed.heights
+	states.hashes = rehashed.hashes
+}
+
+// Mixes bits of a hash value, as the finalizer of MurmurHash3 does.
+func mixHash(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// Calls a function on parts of a range on several goroutines.
+func parallelRange(size sizeType, numOfParts sizeType, fn func(begin sizeType, end sizeType)) {
+	var group sync.WaitGroup
+	for i := 0; i < numOfParts; i++ {
+		begin, end := size*i/numOfParts, size*(i+1)/numOfParts
+		if begin == end {
+			continue
+		}
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			fn(begin, end)
+		}()
+	}
+	group.Wait()
+}
+
+// States of a shard, or states which are fixed between shards, numbered
+// from a given state.
+type dawgSegment struct {
+	states *dawgStates
+	begin  baseType
+	offset baseType
+
+	// Children of states which are fixed between shards are already
+	// numbered across segments.
+	isNumbered bool
+}
+
+// Merges states of shards into a dawg. Keys of shards share prefixes, so
+// states on paths of the prefixes are fixed between shards in the same
+// order as a DawgBuilder fixes them. Then equivalent states of all shards
+// are found in parallel, level by level of their heights, and the first
+// state of each kind is kept, so that the dawg is identical to the one built
+// sequentially.
+type dawgMerger struct {
+	numOfWorkers sizeType
+	segments     []dawgSegment
+	path         [][]dawgTransition
+	root         baseType
+	hasRoot      bool
+
+	numOfStates      sizeType
+	numOfTransitions sizeType
+
+	states   *dawgStates
+	mergedTo []baseType
+	indices  []baseType
+
+	numOfMergedTransitions sizeType
+}
+
+func newDawgMerger(numOfWorkers sizeType) *dawgMerger {
+	return &dawgMerger{
+		numOfWorkers: numOfWorkers,
+		path:         [][]dawgTransition{{}},
+	}
+}
+
+func (m *dawgMerger) addSegment(states *dawgStates, isNumbered bool) {
+	m.segments = append(m.segments, dawgSegment{
+		states:     states,
+		begin:      baseType(m.numOfStates),
+		offset:     baseType(m.numOfTransitions),
+		isNumbered: isNumbered,
+	})
+	m.numOfStates += states.size()
+	m.numOfTransitions += len(states.labels)
+}
+
+// Fixes states of the path which are deeper than a given depth.
+func (m *dawgMerger) fixPath(depth sizeType) {
+	if len(m.path) <= depth+1 {
+		return
+	}
+
+	var states *dawgStates = newDawgStates()
+	var begin baseType = baseType(m.numOfStates)
+	for len(m.path) > depth+1 {
+		var top sizeType = len(m.path) - 1
+		var state dawgTransition = states.add(m.path[top], false)
+		state.child += begin
+		m.path = m.path[:top]
+		if top == 0 {
+			m.root = state.child
+			m.hasRoot = true
+			break
+		}
+
+		var parent []dawgTransition = m.path[top-1]
+		state.label = parent[len(parent)-1].label
+		parent[len(parent)-1] = state
+	}
+	m.addSegment(states, true)
+}
+
+// Adds states of the next shard. The last transition of each unfixed state
+// but the deepest one leads to the next unfixed state, and is updated when
+// that state is fixed.
+func (m *dawgMerger) addShard(shard *dawgShard) {
+	m.fixPath(shard.prevLength)
+
+	var begin baseType = baseType(m.numOfStates)
+	m.addSegment(shard.states, false)
+	for _, state := range shard.path {
+		for i := range state {
+			if state[i].label != 0 {
+				state[i].child += begin
+			}
+		}
+	}
+	m.path[shard.prevLength] = append(m.path[shard.prevLength], shard.path[0]...)
+	m.path = append(m.path, shard.path[1:]...)
+	m.numOfMergedTransitions += shard.numOfMergedTransitions
+}
+
+// Copies states of all segments, so that they are numbered across segments.
+func (m *dawgMerger) collectStates() {
+	m.states = &dawgStates{
+		begins:   make([]baseType, m.numOfStates+1),
+		labels:   make([]ucharType, m.numOfTransitions),
+		children: make([]baseType, m.numOfTransitions),
+		heights:  make([]baseType, m.numOfStates),
+		hashes:   make([]uint64, m.numOfStates),
+		merging:  make([]bool, m.numOfStates),
+	}
+	m.states.begins[m.numOfStates] = baseType(m.numOfTransitions)
+
+	parallelRange(len(m.segments), m.numOfWorkers, func(begin sizeType, end sizeType) {
+		for _, segment := range m.segments[begin:end] {
+			var src *dawgStates = segment.states
+			for i := 0; i < src.size(); i++ {
+				m.states.begins[segment.begin+baseType(i)] = segment.offset + src.begins[i]
+			}
+			copy(m.states.heights[segment.begin:], src.heights)
+			copy(m.states.hashes[segment.begin:], src.hashes)
+			copy(m.states.merging[segment.begin:], src.merging)
+			copy(m.states.labels[segment.offset:], src.labels)
+			for i, child := range src.children {
+				if !segment.isNumbered && src.labels[i] != 0 {
+					child += segment.begin
+				}
+				m.states.children[segment.offset+baseType(i)] = child
+			}
+		}
+	})
+	m.segments = nil
+}
+
+// Finds equivalent states. States of the same height are split into parts
+// by their hashes, and each part is searched on its own goroutine in the
+// order of fixing, so that each state is merged into the first equivalent
+// one. Children are always lower than their parents, and are merged before.
+func (m *dawgMerger) mergeStates() {
+	var numOfStates sizeType = m.states.size()
+	var maxHeight baseType = 0
+	for _, height := range m.states.heights {
+		if height > maxHeight {
+			maxHeight = height
+		}
+	}
+
+	// Sorts states by their heights, keeping the order of fixing.
+	var levels []sizeType = make([]sizeType, maxHeight+2)
+	for _, height := range m.states.heights {
+		levels[height+1]++
+	}
+	for i := 1; i < len(levels); i++ {
+		levels[i] += levels[i-1]
+	}
+	var order []baseType = make([]baseType, numOfStates)
+	var next []sizeType = append([]sizeType(nil), levels...)
+	for i, height := range m.states.heights {
+		order[next[height]] = baseType(i)
+		next[height]++
+	}
+
+	// Makes a hash table for each part, in which states are kept as their
+	// numbers plus 1, so that 0 means an empty slot.
+	var sizes []sizeType = make([]sizeType, m.numOfWorkers)
+	for _, hash := range m.states.hashes {
+		sizes[m.part(hash)]++
+	}
+	var tables [][]baseType = make([][]baseType, m.numOfWorkers)
+	for i, size := range sizes {
+		var tableSize sizeType = 1
+		for tableSize < size*2 {
+			tableSize <<= 1
+		}
+		tables[i] = make([]baseType, tableSize)
+	}
+
+	m.mergedTo = make([]baseType, numOfStates)
+	for height := baseType(0); height <= maxHeight; height++ {
+		var level []baseType = order[levels[height]:levels[height+1]]
+		parallelRange(m.numOfWorkers, m.numOfWorkers, func(begin sizeType, end sizeType) {
+			for part := begin; part < end; part++ {
+				m.mergeLevel(level, part, tables[part])
+			}
+		})
+	}
+}
+
+// Gets a part of states by a hash value.
+func (m *dawgMerger) part(hash uint64) sizeType {
+	return sizeType(hash % uint64(m.numOfWorkers))
+}
+
+// Merges states of a level whose hashes belong to a given part.
+func (m *dawgMerger) mergeLevel(level []baseType, part sizeType, table []baseType) {
+	var mask uint64 = uint64(len(table) - 1)
+	for _, index := range level {
+		var hash uint64 = m.states.hashes[index]
+		if m.part(hash) != part {
+			continue
+		}
+
+		// Low bits of hash values select parts, so high bits select slots.
+		m.mergedTo[index] = index
+		var slot uint64 = (hash >> 32) & mask
+		for ; table[slot] != 0; slot = (slot + 1) & mask {
+			var other baseType = table[slot] - 1
+			if m.states.hashes[other] == hash && m.areEqual(index, other) {
+				m.mergedTo[index] = other
+				break
+			}
+		}
+		if m.mergedTo[index] == index {
+			table[slot] = index + 1
+		}
+	}
+}
+
+// Compares transitions of states whose children are already merged.
+func (m *dawgMerger) areEqual(index baseType, other baseType) bool {
+	var begins []baseType = m.states.begins
+	if begins[index+1]-begins[index] != begins[other+1]-begins[other] {
+		return false
+	}
+	for i, j := begins[index], begins[other]; i < begins[index+1]; i, j = i+1, j+1 {
+		var label ucharType = m.states.labels[i]
+		if label != m.states.labels[j] {
+			return false
+		}
+		var child baseType = m.states.children[i]
+		var otherChild baseType = m.states.children[j]
+		if label != 0 {
+			child = m.mergedTo[child]
+			otherChild = m.mergedTo[otherChild]
+		}
+		if child != otherChild {
+			return false
+		}
+	}
+	return true
+}
+
+// Numbers states which are kept in the order of fixing, and writes their
+// transitions into a builder as it fixes them.
+func (m *dawgMerger) writeStates(db *DawgBuilder) {
+	var numOfStates sizeType = m.states.size()
+	m.indices = make([]baseType, numOfStates)
+	var numOfTransitions baseType = 1
+	for i := 0; i < numOfStates; i++ {
+		var index baseType = baseType(i)
+		var mergedTo baseType = m.mergedTo[index]
+		var size baseType = m.states.begins[index+1] - m.states.begins[index]
+		if mergedTo == index {
+			m.indices[index] = numOfTransitions
+			numOfTransitions += size
+			db.numOfStates++
+		} else {
+			m.numOfMergedTransitions += sizeType(size)
+			m.states.merging[mergedTo] = true
+		}
+		if m.states.merging[index] {
+			m.states.merging[mergedTo] = true
+		}
+	}
+	db.numOfMergedTransitions = m.numOfMergedTransitions
+
+	db.basePool = make([]BaseUnit, numOfTransitions)
+	db.labelPool = make([]ucharType, numOfTransitions)
+	parallelRange(numOfStates, m.numOfWorkers, func(begin sizeType, end sizeType) {
+		for index := baseType(begin); index < baseType(end); index++ {
+			if m.mergedTo[index] != index {
+				continue
+			}
+			var transitionIndex baseType = m.indices[index]
+			for i := m.states.begins[index]; i < m.states.begins[index+1]; i++ {
+				var label ucharType = m.states.labels[i]
+				var base BaseUnit
+				if label == 0 {
+					base = BaseUnit(m.states.children[i]) << 1
+				} else {
+					base = BaseUnit(m.indices[m.mergedTo[m.states.children[i]]]) << 2
+					if i == m.states.begins[index] {
+						base |= 2
+					}
+				}
+				if i+1 < m.states.begins[index+1] {
+					base |= 1
+				}
+				db.basePool[transitionIndex] = base
+				db.labelPool[transitionIndex] = label
+				transitionIndex++
+			}
+		}
+	})
+
+	for i := baseType(0); i < numOfTransitions; i++ {
+		db.flagPool.allocate()
+	}
+	for i := 0; i < numOfStates; i++ {
+		if m.mergedTo[i] == baseType(i) && m.states.merging[i] {
+			db.setMerging(m.indices[i])
+		}
+	}
+
+	db.labelPool[0] = 0xff
+	if m.hasRoot {
+		db.basePool[0] = BaseUnit(m.indices[m.mergedTo[m.root]]) << 2
+	}
+}
@@ -2,6 +2,7 @@ package dawg
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -50,3 +51,82 @@ func TestZeros(t *testing.T) {
 	fmt.Println("Contains 00000:", dict.ContainsString("\x00\x00\x00\x00\x00"))
 	fmt.Println("Contains 000000:", dict.ContainsString("\x00\x00\x00\x00\x00\x00"))
 }
+
+func TestDuplicatePolicy(t *testing.T) {
+	var expected = map[DuplicatePolicy]int64{
+		DuplicateLast:  2,
+		DuplicateFirst: 5,
+		DuplicateError: 5,
+		DuplicateSum:   10,
+		DuplicateMax:   5,
+		DuplicateMin:   2,
+	}
+	for policy, value := range expected {
+		builder := NewDawgBuilder()
+		builder.SetDuplicatePolicy(policy)
+		builder.Insert([]ucharType("apple"), 5)
+		builder.Insert([]ucharType("apple"), 3)
+		err := builder.Insert([]ucharType("apple"), 2)
+		if _, ok := err.(*DuplicateKeyError); ok != (policy == DuplicateError) {
+			t.Errorf("Policy %s: unexpected error %v", policy, err)
+		}
+		if builder.Insert([]ucharType("apple\x01"), 1) != nil || builder.Insert([]ucharType("apple"), 1) != ErrKeyOrder {
+			t.Errorf("Policy %s: order of keys is not checked", policy)
+		}
+
+		dawg := NewDawg()
+		builder.Finish(dawg)
+		if dawg.NumOfDuplicates() != 2 {
+			t.Errorf("Policy %s: expected 2 duplicates, got %d", policy, dawg.NumOfDuplicates())
+		}
+		var result int64
+		if !dawg.Build().FindStringValue64("apple", &result) || result != value {
+			t.Errorf("Policy %s: expected %d, got %d", policy, value, result)
+		}
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	builder := NewDawgBuilder()
+	for _, key := range []string{"apple", "apples", "bapple", "bapples", "caple"} {
+		builder.InsertString(key)
+	}
+	dawg := NewDawg()
+	builder.Finish(dawg)
+
+	var buf strings.Builder
+	if err := dawg.WriteDOT(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	var graph string = buf.String()
+	if strings.Count(graph, "fillcolor") != dawg.NumOfMergingStates() {
+		t.Errorf("Expected %d merging states in:\n%s", dawg.NumOfMergingStates(), graph)
+	}
+	if strings.Count(graph, "doublecircle") != 2 || !strings.Contains(graph, `[label="c"]`) {
+		t.Errorf("Unexpected graph:\n%s", graph)
+	}
+
+	dict := dawg.Build()
+	buf.Reset()
+	if err := dict.WriteDOT(&buf, &DOTOptions{Prefix: "b", MaxDepth: 2, Guide: BuildGuide(dawg, dict)}); err != nil {
+		t.Fatal(err)
+	}
+	graph = buf.String()
+	if strings.Count(graph, "peripheries") != 1 || strings.Contains(graph, `label="l"`) || !strings.Contains(graph, `label="a"`) || !strings.Contains(graph, `label="child"`) {
+		t.Errorf("Unexpected graph of prefix b:\n%s", graph)
+	}
+
+	if dawg.WriteDOT(&buf, &DOTOptions{Prefix: "d"}) == nil || dict.WriteDOT(&buf, &DOTOptions{Prefix: "d"}) == nil {
+		t.Errorf("Missing prefix is not reported")
+	}
+
+	var unit baseType = dict.Root()
+	dict.FollowString("ca", &unit)
+	dictSetOffset(&dict.units[unit], baseType(dict.Size()))
+	if err := dict.WriteDOT(&buf, &DOTOptions{Prefix: "b"}); err != nil {
+		t.Errorf("Invalid unit which is not drawn is reported: %v", err)
+	}
+	if dict.WriteDOT(&buf, nil) == nil || dict.WriteDOT(&buf, &DOTOptions{Prefix: "cap"}) == nil {
+		t.Errorf("Invalid unit is not reported")
+	}
+}
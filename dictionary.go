@@ -2,12 +2,26 @@ package dawg
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
 )
 
+// A dictionary file starts with a size of units, or with this bit and flags
+// if it has extensions. The size follows the flags then.
+const extendedHeaderBit = baseType(1) << 31
+
+// Flags of extensions.
+const (
+	hasValueTableFlag = baseType(1) << iota
+	hasWideUnitsFlag
+	knownHeaderFlags = hasValueTableFlag | hasWideUnitsFlag
+)
+
 type Dictionary struct {
-	units []DictionaryUnit
-	size  sizeType
+	units     []DictionaryUnit
+	wideUnits []wideUnit
+	size      sizeType
+	values    []int64
 }
 
 func NewDictionary() *Dictionary {
@@ -16,6 +30,13 @@ func NewDictionary() *Dictionary {
 
 func (dict *Dictionary) setUnits(units []DictionaryUnit) {
 	dict.units = units
+	dict.wideUnits = nil
+	dict.size = len(units)
+}
+
+func (dict *Dictionary) setWideUnits(units []wideUnit) {
+	dict.units = nil
+	dict.wideUnits = units
 	dict.size = len(units)
 }
 
@@ -24,13 +45,30 @@ func (dict *Dictionary) Size() sizeType {
 }
 
 func (dict *Dictionary) TotalSize() sizeType {
-	return unitSize * dict.size
+	return unitSize*len(dict.units) + wideUnitSize*len(dict.wideUnits) + 8*len(dict.values)
 }
 
 func (dict *Dictionary) FileSize() sizeType {
+	if dict.values != nil {
+		return 12 + dict.TotalSize()
+	} else if dict.wideUnits != nil {
+		return 8 + dict.TotalSize()
+	}
 	return 4 + dict.TotalSize()
 }
 
+// Checks if units are wide. Such dictionaries are built automatically when
+// offsets do not fit into regular units, that is, beyond about 2^29 units.
+// Wide units raise the limit to about 2^31 units.
+func (dict *Dictionary) IsWide() bool {
+	return dict.wideUnits != nil
+}
+
+// Checks if values are kept in a value table.
+func (dict *Dictionary) HasValueTable() bool {
+	return dict.values != nil
+}
+
 // Root index.
 func (dict *Dictionary) Root() baseType {
 	return 0
@@ -38,12 +76,62 @@ func (dict *Dictionary) Root() baseType {
 
 // Checks if a given index is related to the end of a key.
 func (dict *Dictionary) HasValue(index baseType) bool {
+	if dict.wideUnits != nil {
+		return wideHasLeaf(dict.wideUnits[index])
+	}
 	return dictHasLeaf(dict.units[index])
 }
 
-// Gets a value from a given index.
+// Gets a value from a given index. Dictionaries with a value table keep ids
+// of values instead, see Value64.
 func (dict *Dictionary) Value(index baseType) valueType {
-	return dictValue(dict.units[index^dictOffset(dict.units[index])])
+	return dict.leafValue(index ^ dict.offset(index))
+}
+
+// Reads an offset to child units from a non-leaf unit.
+func (dict *Dictionary) offset(index baseType) baseType {
+	if dict.wideUnits != nil {
+		return wideOffset(dict.wideUnits[index])
+	}
+	return dictOffset(dict.units[index])
+}
+
+// Reads a label with a leaf flag from a unit.
+func (dict *Dictionary) label(index baseType) baseType {
+	if dict.wideUnits != nil {
+		return wideLabel(dict.wideUnits[index])
+	}
+	return dictLabel(dict.units[index])
+}
+
+// Checks if a unit is a leaf.
+func (dict *Dictionary) isLeaf(index baseType) bool {
+	if dict.wideUnits != nil {
+		return wideIsLeaf(dict.wideUnits[index])
+	}
+	return dictIsLeaf(dict.units[index])
+}
+
+// Reads a value from a leaf unit.
+func (dict *Dictionary) leafValue(index baseType) valueType {
+	if dict.wideUnits != nil {
+		return wideValue(dict.wideUnits[index])
+	}
+	return dictValue(dict.units[index])
+}
+
+// Gets a 64-bit value from a given index. Unlike Value, it looks values up
+// in a value table.
+func (dict *Dictionary) Value64(index baseType) int64 {
+	return dict.tableValue(dict.Value(index))
+}
+
+// Gets a value which is kept in a unit or referenced from it.
+func (dict *Dictionary) tableValue(value valueType) int64 {
+	if dict.values != nil {
+		return dict.values[value]
+	}
+	return int64(value)
 }
 
 func ReadDictionary(r io.Reader) *Dictionary {
@@ -62,33 +150,264 @@ func (dict *Dictionary) Read(r io.Reader) bool {
 		return false
 	}
 
+	var flags baseType = 0
+	if baseSize&extendedHeaderBit != 0 {
+		flags = baseSize &^ extendedHeaderBit
+		if flags&^knownHeaderFlags != 0 {
+			return false
+		}
+		err = binary.Read(r, binary.LittleEndian, &baseSize)
+		if err != nil {
+			return false
+		}
+	}
+
 	var size sizeType = sizeType(baseSize)
-	var unitsBuf = make([]DictionaryUnit, size)
-	err = binary.Read(r, binary.LittleEndian, &unitsBuf)
+	var unitsBuf []DictionaryUnit
+	var wideUnitsBuf []wideUnit
+	if flags&hasWideUnitsFlag != 0 {
+		wideUnitsBuf = make([]wideUnit, size)
+		err = binary.Read(r, binary.LittleEndian, &wideUnitsBuf)
+	} else {
+		unitsBuf = make([]DictionaryUnit, size)
+		err = binary.Read(r, binary.LittleEndian, &unitsBuf)
+	}
 	if err != nil {
 		return false
 	}
 
-	dict.setUnits(unitsBuf)
+	var values []int64
+	if flags&hasValueTableFlag != 0 {
+		var numOfValues baseType
+		err = binary.Read(r, binary.LittleEndian, &numOfValues)
+		if err != nil {
+			return false
+		}
+		// The number of values is not trusted with an allocation.
+		data, ok := readBytes(r, sizeType(numOfValues)*8)
+		if !ok {
+			return false
+		}
+		values = make([]int64, numOfValues)
+		for i := range values {
+			values[i] = int64(binary.LittleEndian.Uint64(data[i*8:]))
+		}
+	}
+
+	if wideUnitsBuf != nil {
+		dict.setWideUnits(wideUnitsBuf)
+	} else {
+		dict.setUnits(unitsBuf)
+	}
+	dict.values = values
 	return true
 }
 
 // Writes a dictionary to an output stream.
 func (dict *Dictionary) Write(w io.Writer) bool {
+	var flags baseType = 0
+	if dict.values != nil {
+		flags |= hasValueTableFlag
+	}
+	if dict.wideUnits != nil {
+		flags |= hasWideUnitsFlag
+	}
+	if flags != 0 {
+		var header baseType = extendedHeaderBit | flags
+		err := binary.Write(w, binary.LittleEndian, header)
+		if err != nil {
+			return false
+		}
+	}
+
 	var baseSize baseType = baseType(dict.size)
 	err := binary.Write(w, binary.LittleEndian, baseSize)
 	if err != nil {
 		return false
 	}
 
-	err = binary.Write(w, binary.LittleEndian, dict.units)
+	if dict.wideUnits != nil {
+		err = binary.Write(w, binary.LittleEndian, dict.wideUnits)
+	} else {
+		err = binary.Write(w, binary.LittleEndian, dict.units)
+	}
 	if err != nil {
 		return false
 	}
 
+	if dict.values != nil {
+		var numOfValues baseType = baseType(len(dict.values))
+		err = binary.Write(w, binary.LittleEndian, numOfValues)
+		if err != nil {
+			return false
+		}
+		err = binary.Write(w, binary.LittleEndian, dict.values)
+		if err != nil {
+			return false
+		}
+	}
+
 	return true
 }
 
+// Checks that every unit reachable from the root is consistent, so that
+// queries never access units out of range. Returns the first problem found.
+func (dict *Dictionary) Validate() error {
+	_, err := dict.reachableUnits()
+	return err
+}
+
+// Lists reachable non-leaf units in depth-first order, checking their offsets.
+func (dict *Dictionary) reachableUnits() ([]baseType, error) {
+	if dict.size == 0 {
+		return nil, fmt.Errorf("dictionary has no units")
+	}
+
+	var units []baseType
+	var visited []bool = make([]bool, dict.size)
+	var stack []baseType = []baseType{dict.Root()}
+	for len(stack) != 0 {
+		var index baseType = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[index] {
+			continue
+		}
+		visited[index] = true
+		units = append(units, index)
+
+		if err := dict.validateUnit(index); err != nil {
+			return nil, err
+		}
+		var offset baseType = index ^ dict.offset(index)
+		for label := numOfLabels - 1; label > 0; label-- {
+			var childIndex baseType = offset ^ baseType(label)
+			if dict.label(childIndex) == baseType(label) {
+				stack = append(stack, childIndex)
+			}
+		}
+	}
+	return units, nil
+}
+
+// Checks an offset and a value of a non-leaf unit, so that its children can
+// be followed. Units other than the root must lead to some keys.
+func (dict *Dictionary) validateUnit(index baseType) error {
+	if sizeType(index) >= dict.size {
+		return fmt.Errorf("unit %d is out of range", index)
+	}
+	if dict.isLeaf(index) {
+		return fmt.Errorf("unit %d: leaf is used as a node", index)
+	}
+	var offset baseType = index ^ dict.offset(index)
+	if sizeType(offset|0xff) >= dict.size {
+		return fmt.Errorf("unit %d: offset %d is out of range", index, offset)
+	}
+
+	if dict.HasValue(index) {
+		if !dict.isLeaf(offset) {
+			return fmt.Errorf("unit %d: leaf %d is missing", index, offset)
+		}
+		if dict.values != nil && sizeType(dict.leafValue(offset)) >= len(dict.values) {
+			return fmt.Errorf("unit %d: value %d is out of range", index, dict.leafValue(offset))
+		}
+		return nil
+	}
+	for label := 1; label < numOfLabels; label++ {
+		if dict.label(offset^baseType(label)) == baseType(label) {
+			return nil
+		}
+	}
+	if index != dict.Root() {
+		return fmt.Errorf("unit %d: no keys are reachable", index)
+	}
+	return nil
+}
+
+// Checks if there are no keys. Guides of such dictionaries have no units.
+func (dict *Dictionary) isEmpty() bool {
+	var labels [1]ucharType
+	return !dict.HasValue(dict.Root()) && len(dict.appendChildLabels(dict.Root(), labels[:0])) == 0
+}
+
+// Appends labels of all transitions from a unit in ascending order.
+func (dict *Dictionary) appendChildLabels(index baseType, labels []ucharType) []ucharType {
+	for label := 1; label < numOfLabels; label++ {
+		var childIndex baseType = index
+		if dict.Follow(ucharType(label), &childIndex) {
+			labels = append(labels, ucharType(label))
+		}
+	}
+	return labels
+}
+
+// Appends labels of transitions from a unit in the order of a guide, or in
+// ascending order without a guide. The end of a key is listed as a zero label,
+// in its rank for a ranked guide and first otherwise.
+func (dict *Dictionary) AppendGuideLabels(guide SomeGuide, index baseType, labels []ucharType) []ucharType {
+	if guide == nil {
+		if dict.HasValue(index) {
+			labels = append(labels, 0)
+		}
+		return dict.appendChildLabels(index, labels)
+	} else if guide.Size() == 0 {
+		return labels
+	}
+
+	// Stops on guides listing more labels than there are.
+	var hasTerminal bool
+	label, ok := dict.firstGuideLabel(guide, index, &hasTerminal)
+	for i := 0; ok && i <= numOfLabels; i++ {
+		labels = append(labels, label)
+		label, ok = dict.nextGuideLabel(guide, index, label, &hasTerminal)
+	}
+	return labels
+}
+
+// Gets the first label of a transition from a unit in the order of a guide,
+// where a zero label is the end of a key as in AppendGuideLabels. The flag
+// tracks whether the end of a key is still to be listed.
+func (dict *Dictionary) firstGuideLabel(guide SomeGuide, index baseType, hasTerminal *bool) (ucharType, bool) {
+	*hasTerminal = dict.HasValue(index)
+	if _, ok := guide.(*RankedGuide); !ok && *hasTerminal {
+		*hasTerminal = false
+		return 0, true
+	}
+	return dict.guideLabel(guide.Child(index), hasTerminal)
+}
+
+// Gets the label following a given one in the order of a guide.
+func (dict *Dictionary) nextGuideLabel(guide SomeGuide, index baseType, label ucharType, hasTerminal *bool) (ucharType, bool) {
+	if _, ok := guide.(*RankedGuide); !ok && label == 0 {
+		// Plain guides do not list the end of a key among children.
+		return dict.guideLabel(guide.Child(index), hasTerminal)
+	}
+	return dict.guideLabel(guide.Sibling(index^dict.offset(index)^baseType(label)), hasTerminal)
+}
+
+// Ranked guides list the end of a key as a zero label, otherwise it ends the
+// list of labels.
+func (dict *Dictionary) guideLabel(label ucharType, hasTerminal *bool) (ucharType, bool) {
+	if label == 0 {
+		if !*hasTerminal {
+			return 0, false
+		}
+		*hasTerminal = false
+	}
+	return label, true
+}
+
+// Calls fn for every key with its value in ascending order of keys until it
+// returns false. Labels are enumerated by following them, so no guide is
+// needed. The key passed to fn is valid only during the call.
+func (dict *Dictionary) Walk(fn func(key []byte, value int64) bool) {
+	it := NewKeyIterator(dict, nil)
+	for it.Next() {
+		if !fn(it.Key(), it.Value64()) {
+			return
+		}
+	}
+}
+
 // Exact matching
 func (dict *Dictionary) ContainsString(key string) bool {
 	var index baseType = dict.Root()
@@ -134,6 +453,14 @@ func (dict *Dictionary) FindStringValue(key string, value *valueType) bool {
 	*value = dict.Value(index)
 	return true
 }
+func (dict *Dictionary) FindStringValue64(key string, value *int64) bool {
+	var index baseType = dict.Root()
+	if !dict.FollowString(key, &index) || !dict.HasValue(index) {
+		return false
+	}
+	*value = dict.Value64(index)
+	return true
+}
 func (dict *Dictionary) FindStringLenValue(key string, length sizeType, value *valueType) bool {
 	var index baseType = dict.Root()
 	if !dict.FollowStringLen(key, length, &index) || !dict.HasValue(index) {
@@ -145,8 +472,8 @@ func (dict *Dictionary) FindStringLenValue(key string, length sizeType, value *v
 
 // Follows a transition.
 func (dict *Dictionary) Follow(label ucharType, index *baseType) bool {
-	var nextIndex baseType = *index ^ dictOffset(dict.units[*index]) ^ baseType(label)
-	if dictLabel(dict.units[nextIndex]) != label {
+	var nextIndex baseType = *index ^ dict.offset(*index) ^ baseType(label)
+	if dict.label(nextIndex) != baseType(label) {
 		return false
 	}
 	*index = nextIndex
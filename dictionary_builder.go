@@ -13,16 +13,24 @@ const unfixedSize = blockSize * numOfUnfixedBlocks
 const upperMask = ^(offsetMax - 1)
 const lowerMask = 0xFF
 
+// Limit of offsets of regular units, which is lowered in tests to make them
+// overflow.
+var regularOffsetMax baseType = offsetMax << 8
+
 type DictionaryBuilder struct {
 	dawg *Dawg
 	dict *Dictionary
 
+	wide             bool
+	overflowed       bool
 	units            []DictionaryUnit
+	wideUnits        []wideUnit
 	extras           [][]DictionaryExtraUnit
 	labels           []ucharType
 	linkTable        *LinkTable
 	unfixedIndex     baseType
 	numOfUnusedUnits baseType
+	observer         BuildObserver
 }
 
 func NewDictionaryBuilder(dawg *Dawg, dict *Dictionary) *DictionaryBuilder {
@@ -40,6 +48,16 @@ func (dawg *Dawg) Build() *Dictionary {
 	return builder.dict
 }
 
+// Builds a dictionary of wide units even if regular units are enough.
+func (dawg *Dawg) BuildWide() *Dictionary {
+	builder := NewDictionaryBuilder(dawg, NewDictionary())
+	builder.wide = true
+	if !builder.BuildDictionary() {
+		return nil
+	}
+	return builder.dict
+}
+
 func (dawg *Dawg) BuildWithUnused(numOfUnusedUnits *baseType) *Dictionary {
 	builder := NewDictionaryBuilder(dawg, NewDictionary())
 	if !builder.BuildDictionary() {
@@ -49,7 +67,28 @@ func (dawg *Dawg) BuildWithUnused(numOfUnusedUnits *baseType) *Dictionary {
 	return builder.dict
 }
 
+// Sets an observer which receives progress of building.
+func (db *DictionaryBuilder) SetObserver(observer BuildObserver) {
+	db.observer = observer
+}
+
+func (db *DictionaryBuilder) stats() *BuildStats {
+	return &BuildStats{
+		Phase:            DictionaryPhase,
+		NumOfUnits:       sizeType(db.numOfUnits()),
+		NumOfUnusedUnits: sizeType(db.numOfUnusedUnits),
+	}
+}
+
+// Number of units which are not used by the dictionary.
+func (db *DictionaryBuilder) NumOfUnusedUnits() baseType {
+	return db.numOfUnusedUnits
+}
+
 func (db *DictionaryBuilder) numOfUnits() baseType {
+	if db.wide {
+		return baseType(len(db.wideUnits))
+	}
 	return baseType(len(db.units))
 }
 
@@ -61,22 +100,101 @@ func (db *DictionaryBuilder) extra(index baseType) *DictionaryExtraUnit {
 	return &db.extras[index/blockSize][index%blockSize]
 }
 
-// Builds a dictionary from a list-form dawg.
+// Sets a flag to show that a unit has a leaf as a child.
+func (db *DictionaryBuilder) setHasLeaf(index baseType) {
+	if db.wide {
+		wideSetHasLeaf(&db.wideUnits[index])
+	} else {
+		dictSetHasLeaf(&db.units[index])
+	}
+}
+
+// Sets a value to a leaf unit.
+func (db *DictionaryBuilder) setValue(index baseType, value valueType) {
+	if db.wide {
+		wideSetValue(&db.wideUnits[index], value)
+	} else {
+		dictSetValue(&db.units[index], value)
+	}
+}
+
+// Sets a label to a non-leaf unit.
+func (db *DictionaryBuilder) setLabel(index baseType, label ucharType) {
+	if db.wide {
+		wideSetLabel(&db.wideUnits[index], label)
+	} else {
+		dictSetLabel(&db.units[index], label)
+	}
+}
+
+// Sets an offset to a non-leaf unit.
+func (db *DictionaryBuilder) setOffset(index baseType, offset baseType) bool {
+	if db.wide {
+		return wideSetOffset(&db.wideUnits[index], offset)
+	}
+	if offset >= regularOffsetMax {
+		return false
+	}
+	return dictSetOffset(&db.units[index], offset)
+}
+
+// Checks if a relative offset can be kept in a unit. Regular units keep
+// either its upper or its lower bits.
+func (db *DictionaryBuilder) fitsOffset(offset baseType) bool {
+	return db.wide || (offset&upperMask == 0) || (offset&lowerMask == 0)
+}
+
+// Builds a dictionary from a list-form dawg. Units are rebuilt in the wide
+// format only if offsets overflow regular units, which happens when a
+// dictionary grows beyond about 2^29 units. Wide units still have 32-bit
+// indices, so dictionaries of more than about 2^31 units, which lexicons of
+// several billion characters may need, fail to build.
 func (db *DictionaryBuilder) BuildDictionary() bool {
+	if db.observer != nil {
+		db.observer.OnPhaseStart(DictionaryPhase)
+	}
+	if db.buildUnits() {
+		return true
+	}
+	if db.wide || !db.overflowed {
+		return false
+	}
+
+	db.wide = true
+	db.overflowed = false
+	db.units = nil
+	db.extras = nil
+	db.unfixedIndex = 0
+	db.numOfUnusedUnits = 0
+	return db.buildUnits()
+}
+
+func (db *DictionaryBuilder) buildUnits() bool {
 	db.linkTable = NewLinkTable(db.dawg.numOfMergingStates + (db.dawg.numOfMergingStates >> 1))
 	db.reserveUnit(0)
 	db.extra(0).setIsUsed()
-	dictSetOffset(&db.units[0], 1)
-	dictSetLabel(&db.units[0], 0)
+	db.setOffset(0, 1)
+	db.setLabel(0, 0)
 
 	if db.dawg.Size() > 1 {
 		if !db.buildDictionaryIndices(db.dawg.Root(), 0) {
 			return false
 		}
+	} else {
+		// Keeps labels of unused units from matching transitions of the root.
+		db.extra(1).setIsUsed()
 	}
 
 	db.fixAllBlocks()
-	db.dict.setUnits(db.units)
+	if db.wide {
+		db.dict.setWideUnits(db.wideUnits)
+	} else {
+		db.dict.setUnits(db.units)
+	}
+	if db.observer != nil {
+		db.observer.OnPhaseEnd(db.stats())
+	}
+	db.dict.values = db.dawg.values
 	return true
 }
 
@@ -92,11 +210,10 @@ func (db *DictionaryBuilder) buildDictionaryIndices(dawgIndex baseType, dictInde
 		var offset baseType = db.linkTable.Find(dawgChildIndex)
 		if offset != 0 {
 			offset ^= dictIndex
-			if (offset&upperMask == 0) || (offset&lowerMask == 0) {
+			if db.fitsOffset(offset) && db.setOffset(dictIndex, offset) {
 				if db.dawg.IsLeaf(dawgChildIndex) {
-					dictSetHasLeaf(&db.units[dictIndex])
+					db.setHasLeaf(dictIndex)
 				}
-				dictSetOffset(&db.units[dictIndex], offset)
 				return true
 			}
 		}
@@ -138,7 +255,8 @@ func (db *DictionaryBuilder) arrangeChildNodes(dawgIndex baseType, dictIndex bas
 
 	// Finds a good offset.
 	var offset baseType = db.findGoodOffset(dictIndex)
-	if !dictSetOffset(&db.units[dictIndex], dictIndex^offset) {
+	if !db.setOffset(dictIndex, dictIndex^offset) {
+		db.overflowed = true
 		return 0
 	}
 
@@ -148,10 +266,10 @@ func (db *DictionaryBuilder) arrangeChildNodes(dawgIndex baseType, dictIndex bas
 		db.reserveUnit(dictChildIndex)
 
 		if db.dawg.IsLeaf(dawgChildIndex) {
-			dictSetHasLeaf(&db.units[dictIndex])
-			dictSetValue(&db.units[dictChildIndex], db.dawg.Value(dawgChildIndex))
+			db.setHasLeaf(dictIndex)
+			db.setValue(dictChildIndex, db.dawg.Value(dawgChildIndex))
 		} else {
-			dictSetLabel(&db.units[dictChildIndex], db.labels[i])
+			db.setLabel(dictChildIndex, db.labels[i])
 		}
 
 		dawgChildIndex = db.dawg.Sibling(dawgChildIndex)
@@ -189,8 +307,7 @@ func (db *DictionaryBuilder) isGoodOffset(index baseType, offset baseType) bool
 		return false
 	}
 
-	var relativeOffset baseType = index ^ offset
-	if (relativeOffset&lowerMask != 0) && (relativeOffset&upperMask != 0) {
+	if !db.fitsOffset(index ^ offset) {
 		return false
 	}
 
@@ -235,7 +352,14 @@ func (db *DictionaryBuilder) expandDictionary() {
 		db.fixBlock(srcNumOfBlocks - numOfUnfixedBlocks)
 	}
 
-	db.units = append(db.units, make([]DictionaryUnit, blockSize)...)
+	if db.wide {
+		db.wideUnits = append(db.wideUnits, make([]wideUnit, blockSize)...)
+	} else {
+		db.units = append(db.units, make([]DictionaryUnit, blockSize)...)
+	}
+	if db.observer != nil && destNumOfUnits%progressInterval == 0 {
+		db.observer.OnProgress(db.stats())
+	}
 	db.extras = append(db.extras, nil)
 
 	// Allocates memory to a new block.
@@ -297,7 +421,7 @@ func (db *DictionaryBuilder) fixBlock(blockId baseType) {
 	for index := begin; index != end; index++ {
 		if !db.extra(index).isFixed() {
 			db.reserveUnit(index)
-			dictSetLabel(&db.units[index], ucharType(index^unusedOffsetForLabel))
+			db.setLabel(index, ucharType(index^unusedOffsetForLabel))
 			db.numOfUnusedUnits += 1
 		}
 	}
@@ -0,0 +1,372 @@
+package dawg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	dawg, dict, _ := buildTestLexicon(t)
+	guide := BuildGuide(dawg, dict)
+	rankedGuide := BuildRankedGuide(dawg, dict)
+	index := BuildIndex(dict, guide)
+
+	if err := dict.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := guide.Validate(dict); err != nil {
+		t.Fatal(err)
+	}
+	if err := rankedGuide.Validate(dict); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Validate(dict, guide); err != nil {
+		t.Fatal(err)
+	}
+	rankedIndex := BuildIndex(dict, rankedGuide)
+	if err := rankedIndex.Validate(dict, rankedGuide); err != nil {
+		t.Fatal(err)
+	}
+
+	var unit baseType = dict.Root()
+	dict.FollowString("b", &unit)
+
+	index.units[unit]++
+	if index.Validate(dict, guide) == nil {
+		t.Errorf("Wrong count is not detected")
+	}
+	index.units[unit]--
+
+	rankedIndex.units[unit]++
+	if rankedIndex.Validate(dict, rankedGuide) == nil {
+		t.Errorf("Wrong count with ranked guide is not detected")
+	}
+	rankedIndex.units[unit]--
+
+	_, aggregates := BuildAggregateIndex(dict, rankedGuide)
+	if err := aggregates.Validate(dict); err != nil {
+		t.Fatal(err)
+	}
+	aggregates.units[unit].Sum++
+	if aggregates.Validate(dict) == nil {
+		t.Errorf("Wrong aggregate is not detected")
+	}
+	if (&AggregateIndex{}).Validate(dict) == nil {
+		t.Errorf("Wrong size of aggregates is not detected")
+	}
+
+	if ValidateGuide(guide, dict) != nil || ValidateGuide(rankedGuide, dict) != nil {
+		t.Errorf("Valid guides are not validated by ValidateGuide")
+	}
+	guide.units[unit].Child++
+	if guide.Validate(dict) == nil || ValidateGuide(guide, dict) == nil {
+		t.Errorf("Wrong guide label is not detected")
+	}
+	guide.units[unit].Child--
+
+	rankedGuide.units[unit].Child = 0
+	if rankedGuide.Validate(dict) == nil {
+		t.Errorf("Missing ranked guide labels are not detected")
+	}
+
+	dictSetOffset(&dict.units[unit], baseType(dict.Size()))
+	if dict.Validate() == nil {
+		t.Errorf("Offset out of range is not detected")
+	}
+}
+
+func TestDictionaryValue64(t *testing.T) {
+	var keys = []string{"apple", "apply", "banana", "cherry", "durian", "office"}
+	var values = []int64{7, 7, -3, 1 << 40, 0, -1 << 50}
+
+	builder := NewDawgBuilder()
+	if builder.InsertStringValue64("", -1) {
+		t.Fatalf("Negative value is inserted without a value table")
+	}
+	builder.UseValueTable()
+	for i, key := range keys {
+		if !builder.InsertStringValue64(key, values[i]) {
+			t.Fatalf("Failed to insert %s", key)
+		}
+	}
+	dawg := NewDawg()
+	builder.Finish(dawg)
+	dict := dawg.Build()
+	if !dict.HasValueTable() {
+		t.Fatalf("Value table is not used")
+	}
+	if err := dict.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if !dict.Write(&buf) || buf.Len() != dict.FileSize() {
+		t.Fatalf("Failed to write dictionary")
+	}
+	// A huge number of values in a truncated file fails without allocating
+	// the table.
+	var corrupt []byte = append([]byte(nil), buf.Bytes()[:buf.Len()-8*len(dict.values)]...)
+	binary.LittleEndian.PutUint32(corrupt[len(corrupt)-4:], 0xffffffff)
+	if ReadDictionary(bytes.NewReader(corrupt)) != nil {
+		t.Errorf("Truncated value table is read")
+	}
+	dict = ReadDictionary(&buf)
+	if dict == nil {
+		t.Fatalf("Failed to read dictionary")
+	}
+	for i, key := range keys {
+		var value int64
+		if !dict.FindStringValue64(key, &value) || value != values[i] {
+			t.Errorf("Value of %s: expected %d, got %d", key, values[i], value)
+		}
+	}
+
+	guide := BuildRankedGuide(dawg, dict)
+	completer := NewRankedCompleter(dict, guide)
+	completer.Start(dict.Root())
+	var last int64 = 1 << 62
+	var count int = 0
+	for completer.Next() {
+		if completer.Value64() > last {
+			t.Errorf("Wrong order: %d after %d", completer.Value64(), last)
+		}
+		last = completer.Value64()
+		count++
+	}
+	if count != len(keys) {
+		t.Errorf("Expected %d keys, got %d", len(keys), count)
+	}
+
+	builder = NewDawgBuilder()
+	builder.InsertStringValue64("apple", 1)
+	builder.Finish(dawg)
+	if dict = dawg.Build(); dict.HasValueTable() {
+		t.Errorf("Value table is used for small values")
+	}
+}
+
+func TestDictionaryWide(t *testing.T) {
+	dawg, dict, lexicon := buildTestLexicon(t)
+	wideDict := dawg.BuildWide()
+	if wideDict == nil || !wideDict.IsWide() {
+		t.Fatalf("Failed to build wide dictionary")
+	}
+	if err := wideDict.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if !wideDict.Write(&buf) || buf.Len() != wideDict.FileSize() {
+		t.Fatalf("Failed to write dictionary")
+	}
+	wideDict = ReadDictionary(&buf)
+	if wideDict == nil || !wideDict.IsWide() {
+		t.Fatalf("Failed to read dictionary")
+	}
+
+	for key, value := range lexicon {
+		var wideValue valueType
+		if !wideDict.FindStringValue(key, &wideValue) || wideValue != value {
+			t.Errorf("Value of %s: expected %d, got %d", key, value, wideValue)
+		}
+		if wideDict.ContainsString(key + "\xff") {
+			t.Errorf("Unexpected key %s", key+"\xff")
+		}
+	}
+
+	guide := BuildRankedGuide(dawg, wideDict)
+	if err := guide.Validate(wideDict); err != nil {
+		t.Fatal(err)
+	}
+	expected := NewRankedCompleter(dict, BuildRankedGuide(dawg, dict))
+	completer := NewRankedCompleter(wideDict, guide)
+	expected.Start(dict.Root())
+	completer.Start(wideDict.Root())
+	for expected.Next() {
+		if !completer.Next() || completer.Key() != expected.Key() || completer.Value() != expected.Value() {
+			t.Fatalf("Expected %s, got %s", expected.Key(), completer.Key())
+		}
+	}
+	if completer.Next() {
+		t.Errorf("Unexpected key %s", completer.Key())
+	}
+}
+
+func TestDictionaryWideFallback(t *testing.T) {
+	var key = func(i int) string {
+		return fmt.Sprintf("%04d%08x", i, uint32(i)*2654435761)
+	}
+	builder := NewDawgBuilder()
+	for i := 0; i < 4096; i++ {
+		if !builder.InsertStringValue(key(i), valueType(i)) {
+			t.Fatalf("Failed to insert key %d", i)
+		}
+	}
+	dawg := NewDawg()
+	builder.Finish(dawg)
+	if dict := dawg.Build(); dict == nil || dict.IsWide() {
+		t.Fatalf("Dictionary is wide without overflowing")
+	}
+
+	// Lowers the limit of regular offsets, so that building overflows.
+	defer func(limit baseType) { regularOffsetMax = limit }(regularOffsetMax)
+	regularOffsetMax = 1 << 12
+	dictBuilder := NewDictionaryBuilder(dawg, NewDictionary())
+	if !dictBuilder.BuildDictionary() {
+		t.Fatalf("Failed to fall back to wide units")
+	}
+	dict := dictBuilder.dict
+	if !dict.IsWide() || dict.Size() <= 1<<12 {
+		t.Fatalf("Dictionary of %d units is not wide after overflowing", dict.Size())
+	}
+	if err := dict.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4096; i++ {
+		var value valueType
+		if !dict.FindStringValue(key(i), &value) || value != valueType(i) {
+			t.Errorf("Value of %s: expected %d, got %d", key(i), i, value)
+		}
+	}
+}
+
+func TestDictionaryWalk(t *testing.T) {
+	_, dict, lexicon := buildTestLexicon(t)
+
+	var prevKey string
+	var count int = 0
+	dict.Walk(func(key []byte, value int64) bool {
+		if count != 0 && string(key) <= prevKey {
+			t.Errorf("Key %s follows %s", key, prevKey)
+		}
+		if expected, ok := lexicon[string(key)]; !ok || int64(expected) != value {
+			t.Errorf("Value of %s: expected %d, got %d", key, expected, value)
+		}
+		prevKey = string(key)
+		count++
+		return true
+	})
+	if count != len(lexicon) {
+		t.Errorf("Expected %d keys, got %d", len(lexicon), count)
+	}
+
+	builder := NewDawgBuilder()
+	builder.UseValueTable()
+	builder.InsertStringValue64("", -1)
+	builder.InsertStringValue64("\xff", 2)
+	builder.InsertStringValue64("\xff\xff", 3)
+	dawg := NewDawg()
+	builder.Finish(dawg)
+	var keys []string
+	dawg.Build().Walk(func(key []byte, value int64) bool {
+		keys = append(keys, fmt.Sprintf("%q=%d", key, value))
+		return len(keys) < 2
+	})
+	if strings.Join(keys, " ") != `""=-1 "\xff"=2` {
+		t.Errorf("Unexpected keys %v", keys)
+	}
+}
+
+func TestAppendGuideLabels(t *testing.T) {
+	dawg, dict, _ := buildTestLexicon(t)
+	var index baseType = dict.Root()
+	dict.FollowString("bin", &index)
+
+	var tests = []struct {
+		guide    SomeGuide
+		expected string
+	}{
+		{nil, "\x00ad"},
+		{BuildGuide(dawg, dict), "\x00ad"},
+		{BuildRankedGuide(dawg, dict), "\x00da"},
+		{BuildRankedGuideOrder(dawg, dict, AscendingOrder), "da\x00"},
+	}
+	for i, test := range tests {
+		if labels := dict.AppendGuideLabels(test.guide, index, nil); string(labels) != test.expected {
+			t.Errorf("Test %d: expected labels %q, got %q", i, test.expected, labels)
+		}
+	}
+}
+
+func TestEmptyDictionary(t *testing.T) {
+	dawg := NewDawg()
+	NewDawgBuilder().Finish(dawg)
+	dict := dawg.Build()
+	if dict == nil {
+		t.Fatal("Failed to build dictionary")
+	}
+
+	for label := 1; label < numOfLabels; label++ {
+		var index baseType = dict.Root()
+		if dict.Follow(ucharType(label), &index) {
+			t.Errorf("Empty dictionary follows label %d", label)
+		}
+	}
+	if dict.ContainsString("") {
+		t.Errorf("Empty dictionary contains the empty key")
+	}
+	if err := dict.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValueTableAPIs(t *testing.T) {
+	var keys = []string{"a", "ab", "abc", "b", "bc"}
+	var values = []int64{3, 1 << 40, -2, 7, 1}
+
+	builder := NewDawgBuilder()
+	builder.UseValueTable()
+	for i, key := range keys {
+		if !builder.InsertStringValue64(key, values[i]) {
+			t.Fatalf("Failed to insert %s", key)
+		}
+	}
+	dawg := NewDawg()
+	builder.Finish(dawg)
+	dict := dawg.Build()
+	guide := BuildGuide(dawg, dict)
+	rankedGuide := BuildRankedGuide(dawg, dict)
+	lexicon := map[string]int64{}
+	for i, key := range keys {
+		lexicon[key] = values[i]
+	}
+
+	completer := NewCompleter(dict, guide)
+	var count int = 0
+	completer.Range("", "z", func(key []byte, value int64) bool {
+		if value != lexicon[string(key)] {
+			t.Errorf("Range: value of %s is %d", key, value)
+		}
+		count++
+		return true
+	})
+	if count != len(keys) {
+		t.Errorf("Range yields %d keys", count)
+	}
+	if key, value, ok := completer.Successor("a"); !ok || key != "ab" || value != 1<<40 {
+		t.Errorf("Successor(\"a\") = %q, %d", key, value)
+	}
+	if key, value, ok := completer.Predecessor("b"); !ok || key != "abc" || value != -2 {
+		t.Errorf("Predecessor(\"b\") = %q, %d", key, value)
+	}
+
+	indexer := NewIndexer(dict, guide, BuildIndex(dict, guide))
+	indexer.Iterate(0, NotFound, func(i baseType, key []byte, value int64) bool {
+		if value != lexicon[string(key)] {
+			t.Errorf("Iterate: value of %s is %d", key, value)
+		}
+		return true
+	})
+
+	results := NewRankedCompleter(dict, rankedGuide).TopK("", 2, nil)
+	if len(results) != 2 || string(results[0].Key) != "ab" || results[0].Value != 1<<40 || results[1].Value != 7 {
+		t.Errorf("TopK returned %v", results)
+	}
+	results = NewFuzzyCompleter(dict, rankedGuide, 0).TopK("a", 1, nil)
+	if len(results) != 1 || results[0].Value != 1<<40 {
+		t.Errorf("Fuzzy TopK returned %v", results)
+	}
+}
@@ -49,9 +49,10 @@ func dictValue(base DictionaryUnit) valueType {
 	return valueType(base &^ isLeafBit)
 }
 
-// Reads a label with a leaf flag from a non-leaf unit.
-func dictLabel(base DictionaryUnit) ucharType {
-	return ucharType(base & (isLeafBit | 0xff))
+// Reads a label with a leaf flag from a non-leaf unit. The flag is kept so
+// that a leaf unit never matches a label.
+func dictLabel(base DictionaryUnit) baseType {
+	return base & (isLeafBit | 0xff)
 }
 
 // Reads an offset to child units from a non-leaf unit.
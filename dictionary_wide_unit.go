@@ -0,0 +1,67 @@
+package dawg
+
+// Wide units are used by dictionaries which are too large for offsets of
+// regular units. A leaf keeps a value in the lower 32 bits, and a non-leaf
+// unit keeps an offset in the upper 32 bits instead of packing it with an
+// extension bit, so that any offset below wideOffsetMax can be used.
+//
+// Indices of units, guides and indexes are still 32-bit, and the top bit of
+// an offset is taken by the leaf flag, so wide dictionaries are limited to
+// about 2^31 units (16 GiB). This is only 4 times as much as regular units
+// allow, which reach offsets up to 2^29.
+type wideUnit = uint64
+
+const wideOffsetMax = baseType(1) << 31
+const wideIsLeafBit = wideUnit(1) << 63
+const wideHasLeafBit = wideUnit(1) << 8
+
+const wideUnitSize = 8 // Replacement for sizeof(wideUnit)
+
+// Sets a flag to show that a unit has a leaf as a child.
+func wideSetHasLeaf(base *wideUnit) {
+	*base |= wideHasLeafBit
+}
+
+// Sets a value to a leaf unit.
+func wideSetValue(base *wideUnit, value valueType) {
+	*base = wideUnit(value) | wideIsLeafBit
+}
+
+// Sets a label to a non-leaf unit.
+func wideSetLabel(base *wideUnit, label ucharType) {
+	*base = (*base &^ 0xff) | wideUnit(label)
+}
+
+// Sets an offset to a non-leaf unit.
+func wideSetOffset(base *wideUnit, offset baseType) bool {
+	if offset >= wideOffsetMax {
+		return false
+	}
+
+	*base = (*base & (wideIsLeafBit | wideHasLeafBit | 0xff)) | (wideUnit(offset) << 32)
+	return true
+}
+
+// Checks if a unit has a leaf as a child or not.
+func wideHasLeaf(base wideUnit) bool {
+	return base&wideHasLeafBit != 0
+}
+
+// Reads a value from a leaf unit.
+func wideValue(base wideUnit) valueType {
+	return valueType(baseType(base))
+}
+
+// Reads a label with a leaf flag in the same form as dictLabel does.
+func wideLabel(base wideUnit) baseType {
+	return baseType(base&0xff) | (baseType(base>>32) & isLeafBit)
+}
+
+// Reads an offset to child units from a non-leaf unit.
+func wideOffset(base wideUnit) baseType {
+	return baseType(base>>32) &^ isLeafBit
+}
+
+func wideIsLeaf(base wideUnit) bool {
+	return (base & wideIsLeafBit) != 0
+}
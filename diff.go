@@ -0,0 +1,128 @@
+package dawg
+
+import "bytes"
+
+// Kind of a difference between dictionaries.
+type DiffKind ucharType
+
+const (
+	// A key is only in the new dictionary.
+	DiffAdded DiffKind = iota
+	// A key is only in the old dictionary.
+	DiffRemoved
+	// A key is in both dictionaries with different values.
+	DiffChanged
+)
+
+func (kind DiffKind) String() string {
+	switch kind {
+	case DiffRemoved:
+		return "removed"
+	case DiffChanged:
+		return "changed"
+	}
+	return "added"
+}
+
+// A key which differs between dictionaries. A value which the key does not
+// have in one of them is 0.
+type DiffEntry struct {
+	Kind     DiffKind
+	Key      []byte
+	OldValue int64
+	NewValue int64
+}
+
+// Iterates over differences between two dictionaries in ascending order of
+// keys, walking both of them at once.
+type DiffIterator struct {
+	old    *KeyIterator
+	new    *KeyIterator
+	hasOld bool
+	hasNew bool
+	entry  DiffEntry
+
+	numOfAdded     sizeType
+	numOfRemoved   sizeType
+	numOfChanged   sizeType
+	numOfUnchanged sizeType
+}
+
+// Creates an iterator over differences. Guides are optional, and make
+// walking faster.
+func NewDiffIterator(oldDict *Dictionary, oldGuide *Guide, newDict *Dictionary, newGuide *Guide) *DiffIterator {
+	it := &DiffIterator{
+		old: NewKeyIterator(oldDict, oldGuide),
+		new: NewKeyIterator(newDict, newGuide),
+	}
+	it.hasOld = it.old.Next()
+	it.hasNew = it.new.Next()
+	return it
+}
+
+// Gets the current difference, which is valid until the next call of Next.
+func (it *DiffIterator) Entry() *DiffEntry {
+	return &it.entry
+}
+
+// Number of differences and unchanged keys found so far.
+func (it *DiffIterator) NumOfAdded() sizeType {
+	return it.numOfAdded
+}
+func (it *DiffIterator) NumOfRemoved() sizeType {
+	return it.numOfRemoved
+}
+func (it *DiffIterator) NumOfChanged() sizeType {
+	return it.numOfChanged
+}
+func (it *DiffIterator) NumOfUnchanged() sizeType {
+	return it.numOfUnchanged
+}
+
+// Moves to the next difference. Returns false after the last one.
+func (it *DiffIterator) Next() bool {
+	for it.hasOld || it.hasNew {
+		var order int
+		if !it.hasOld {
+			order = 1
+		} else if !it.hasNew {
+			order = -1
+		} else {
+			order = bytes.Compare(it.old.Key(), it.new.Key())
+		}
+
+		if order < 0 {
+			it.setEntry(DiffRemoved, it.old.Key(), it.old.Value64(), 0)
+			it.numOfRemoved++
+			it.hasOld = it.old.Next()
+			return true
+		} else if order > 0 {
+			it.setEntry(DiffAdded, it.new.Key(), 0, it.new.Value64())
+			it.numOfAdded++
+			it.hasNew = it.new.Next()
+			return true
+		}
+
+		var changed bool = it.old.Value64() != it.new.Value64()
+		if changed {
+			it.setEntry(DiffChanged, it.old.Key(), it.old.Value64(), it.new.Value64())
+			it.numOfChanged++
+		} else {
+			it.numOfUnchanged++
+		}
+		it.hasOld = it.old.Next()
+		it.hasNew = it.new.Next()
+		if changed {
+			return true
+		}
+	}
+	return false
+}
+
+// Copies a key, since iterators reuse their buffers.
+func (it *DiffIterator) setEntry(kind DiffKind, key []byte, oldValue int64, newValue int64) {
+	it.entry.Kind = kind
+	it.entry.Key = append(it.entry.Key[:0], key...)
+	it.entry.OldValue = oldValue
+	it.entry.NewValue = newValue
+}
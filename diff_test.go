@@ -0,0 +1,48 @@
+package dawg
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDiffIterator(t *testing.T) {
+	var build = func(keys []string, values []int64) (*Dawg, *Dictionary) {
+		builder := NewDawgBuilder()
+		builder.UseValueTable()
+		for i, key := range keys {
+			builder.InsertStringValue64(key, values[i])
+		}
+		dawg := NewDawg()
+		builder.Finish(dawg)
+		return dawg, dawg.Build()
+	}
+
+	oldDawg, oldDict := build([]string{"", "apple", "apples", "banana", "cherry"}, []int64{1, 2, 3, 4, 5})
+	newDawg, newDict := build([]string{"apple", "apples", "bananas", "cherry", "durian"}, []int64{2, 7, 4, 5, -1})
+	var expected = `removed "" 1 0; changed "apples" 3 7; removed "banana" 4 0; added "bananas" 0 4; added "durian" 0 -1`
+
+	for _, withGuides := range []bool{false, true} {
+		var oldGuide, newGuide *Guide
+		if withGuides {
+			oldGuide = BuildGuide(oldDawg, oldDict)
+			newGuide = BuildGuide(newDawg, newDict)
+		}
+		it := NewDiffIterator(oldDict, oldGuide, newDict, newGuide)
+		var entries []string
+		for it.Next() {
+			entry := it.Entry()
+			entries = append(entries, fmt.Sprintf("%s %q %d %d", entry.Kind, entry.Key, entry.OldValue, entry.NewValue))
+		}
+		if strings.Join(entries, "; ") != expected {
+			t.Errorf("Guides %t: unexpected diff %q", withGuides, entries)
+		}
+		if it.NumOfAdded() != 2 || it.NumOfRemoved() != 2 || it.NumOfChanged() != 1 || it.NumOfUnchanged() != 2 {
+			t.Errorf("Guides %t: unexpected counts", withGuides)
+		}
+	}
+
+	if NewDiffIterator(oldDict, nil, oldDict, nil).Next() {
+		t.Errorf("Dictionary differs from itself")
+	}
+}
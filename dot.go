@@ -0,0 +1,236 @@
+package dawg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Limits of graphs written by WriteDOT.
+type DOTOptions struct {
+	// Draws only states reachable by keys which start with a prefix.
+	Prefix string
+	// Stops at states deeper than a given number of transitions from the
+	// prefix. States are not limited if it is not positive.
+	MaxDepth int
+	// Draws guide transitions of a dictionary as dashed edges.
+	Guide SomeGuide
+}
+
+// Formats a label of a transition. Labels out of printable ASCII are written
+// as hex codes, as they are usually parts of multibyte characters.
+func dotLabel(label ucharType) string {
+	switch {
+	case label == '"' || label == '\\':
+		return `\` + string(rune(label))
+	case label >= 0x20 && label < 0x7f:
+		return string(rune(label))
+	}
+	return fmt.Sprintf("0x%02x", label)
+}
+
+// Writes a graph line by line, remembering the first error.
+type dotWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func newDOTWriter(w io.Writer) *dotWriter {
+	return &dotWriter{w: bufio.NewWriter(w)}
+}
+
+func (dw *dotWriter) printf(format string, args ...interface{}) {
+	if dw.err == nil {
+		_, dw.err = fmt.Fprintf(dw.w, format, args...)
+	}
+}
+
+func (dw *dotWriter) flush() error {
+	if dw.err == nil {
+		dw.err = dw.w.Flush()
+	}
+	return dw.err
+}
+
+// Writes a node of a state, which is final if it has a value.
+func (dw *dotWriter) node(name string, id baseType, hasValue bool, value int64, attrs string) {
+	if hasValue {
+		dw.printf("  %s%d [shape=doublecircle, label=\"%d\\n= %d\"%s];\n", name, id, id, value, attrs)
+	} else {
+		dw.printf("  %s%d [label=\"%d\"%s];\n", name, id, id, attrs)
+	}
+}
+
+// Finds a state by following a prefix from the root. States of a dawg are
+// identified by their first transitions.
+func (dawg *Dawg) followPrefix(prefix string) (baseType, bool) {
+	if dawg.Size() == 0 {
+		return 0, false
+	}
+	var state baseType = dawg.Child(dawg.Root())
+	for i := 0; i < len(prefix); i++ {
+		var next baseType = 0
+		for index := state; index != 0; index = dawg.Sibling(index) {
+			if dawg.Label(index) == prefix[i] {
+				next = index
+				break
+			}
+		}
+		if next == 0 {
+			return 0, false
+		}
+		state = dawg.Child(next)
+	}
+	return state, true
+}
+
+// Writes states and transitions of a dawg in GraphViz DOT format. Final
+// states are drawn with double circles and merging states are filled.
+func (dawg *Dawg) WriteDOT(w io.Writer, options *DOTOptions) error {
+	if options == nil {
+		options = &DOTOptions{}
+	}
+	state, ok := dawg.followPrefix(options.Prefix)
+	if !ok {
+		return fmt.Errorf("prefix %q is not found", options.Prefix)
+	}
+
+	dw := newDOTWriter(w)
+	dw.printf("digraph dawg {\n")
+	dw.printf("  rankdir=LR;\n")
+	dw.printf("  node [shape=circle];\n")
+
+	var depths map[baseType]int = map[baseType]int{state: 0}
+	var queue []baseType = []baseType{state}
+	if state == 0 {
+		// The root has no transitions.
+		queue = nil
+	}
+	for len(queue) != 0 && dw.err == nil {
+		state = queue[0]
+		queue = queue[1:]
+		var depth int = depths[state]
+
+		var attrs string = ""
+		if dawg.IsMerging(state) {
+			attrs += ", style=filled, fillcolor=lightgrey"
+		}
+		var truncated bool = options.MaxDepth > 0 && depth >= options.MaxDepth
+		if truncated {
+			attrs += ", peripheries=2, color=grey"
+		}
+		var hasValue bool = dawg.IsLeaf(state)
+		var value int64 = 0
+		if hasValue {
+			value = dawg.Value64(state)
+		}
+		dw.node("s", state, hasValue, value, attrs)
+		if truncated {
+			continue
+		}
+
+		for index := state; index != 0; index = dawg.Sibling(index) {
+			if dawg.IsLeaf(index) {
+				continue
+			}
+			var child baseType = dawg.Child(index)
+			if _, ok := depths[child]; !ok {
+				depths[child] = depth + 1
+				queue = append(queue, child)
+			}
+			dw.printf("  s%d -> s%d [label=\"%s\"];\n", state, child, dotLabel(dawg.Label(index)))
+		}
+	}
+
+	dw.printf("}\n")
+	return dw.flush()
+}
+
+// Writes units of a dictionary which are reachable from the root in GraphViz
+// DOT format. Transitions of a guide are drawn with dashed edges if a guide
+// is given. Only units which are drawn are validated, and writing stops at
+// the first invalid one with an error.
+func (dict *Dictionary) WriteDOT(w io.Writer, options *DOTOptions) error {
+	if options == nil {
+		options = &DOTOptions{}
+	}
+	if dict.size == 0 {
+		return fmt.Errorf("dictionary has no units")
+	}
+	var index baseType = dict.Root()
+	for i := 0; i < len(options.Prefix); i++ {
+		if err := dict.validateUnit(index); err != nil {
+			return err
+		}
+		if !dict.Follow(options.Prefix[i], &index) {
+			return fmt.Errorf("prefix %q is not found", options.Prefix)
+		}
+	}
+
+	dw := newDOTWriter(w)
+	dw.printf("digraph dictionary {\n")
+	dw.printf("  rankdir=LR;\n")
+	dw.printf("  node [shape=circle];\n")
+
+	var guide SomeGuide = options.Guide
+	if guide != nil && guide.Size() != dict.Size() {
+		guide = nil
+	}
+
+	var depths map[baseType]int = map[baseType]int{index: 0}
+	var queue []baseType = []baseType{index}
+	var labels []ucharType
+	for len(queue) != 0 && dw.err == nil {
+		index = queue[0]
+		queue = queue[1:]
+		var depth int = depths[index]
+		if dw.err = dict.validateUnit(index); dw.err != nil {
+			break
+		}
+
+		var attrs string = ""
+		var truncated bool = options.MaxDepth > 0 && depth >= options.MaxDepth
+		if truncated {
+			attrs += ", peripheries=2, color=grey"
+		}
+		var hasValue bool = dict.HasValue(index)
+		var value int64 = 0
+		if hasValue {
+			value = dict.Value64(index)
+		}
+		dw.node("u", index, hasValue, value, attrs)
+		if truncated {
+			continue
+		}
+
+		labels = dict.appendChildLabels(index, labels[:0])
+		for _, label := range labels {
+			var childIndex baseType = index
+			dict.Follow(label, &childIndex)
+			if _, ok := depths[childIndex]; !ok {
+				depths[childIndex] = depth + 1
+				queue = append(queue, childIndex)
+			}
+			dw.printf("  u%d -> u%d [label=\"%s\"];\n", index, childIndex, dotLabel(label))
+		}
+
+		if guide == nil {
+			continue
+		}
+		if label := guide.Child(index); label != 0 {
+			var childIndex baseType = index
+			if dict.Follow(label, &childIndex) {
+				dw.printf("  u%d -> u%d [style=dashed, color=blue, label=\"child\"];\n", index, childIndex)
+			}
+		}
+		if label := guide.Sibling(index); label != 0 && depth > 0 {
+			// Siblings share an offset of their parent, which is drawn unless the
+			// unit is the first one.
+			var siblingIndex baseType = index ^ dict.label(index) ^ baseType(label)
+			dw.printf("  u%d -> u%d [style=dashed, color=red, label=\"sibling\"];\n", index, siblingIndex)
+		}
+	}
+
+	dw.printf("}\n")
+	return dw.flush()
+}
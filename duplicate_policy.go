@@ -0,0 +1,67 @@
+package dawg
+
+import "fmt"
+
+// What a builder does when the same key is inserted again.
+type DuplicatePolicy ucharType
+
+const (
+	// The last value is kept.
+	DuplicateLast DuplicatePolicy = iota
+	// The first value is kept.
+	DuplicateFirst
+	// The key is rejected with a DuplicateKeyError.
+	DuplicateError
+	// Values are summed up.
+	DuplicateSum
+	// The largest value is kept.
+	DuplicateMax
+	// The smallest value is kept.
+	DuplicateMin
+)
+
+func (policy DuplicatePolicy) String() string {
+	switch policy {
+	case DuplicateFirst:
+		return "first"
+	case DuplicateError:
+		return "error"
+	case DuplicateSum:
+		return "sum"
+	case DuplicateMax:
+		return "max"
+	case DuplicateMin:
+		return "min"
+	}
+	return "last"
+}
+
+// Combines a value of a key with a value inserted again.
+func (policy DuplicatePolicy) merge(value int64, newValue int64) int64 {
+	switch policy {
+	case DuplicateFirst, DuplicateError:
+		return value
+	case DuplicateSum:
+		return value + newValue
+	case DuplicateMax:
+		if newValue > value {
+			return newValue
+		}
+		return value
+	case DuplicateMin:
+		if newValue < value {
+			return newValue
+		}
+		return value
+	}
+	return newValue
+}
+
+// Error returned when a key is inserted again under DuplicateError.
+type DuplicateKeyError struct {
+	Key string
+}
+
+func (err *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("duplicate key: %q", err.Key)
+}
@@ -0,0 +1,291 @@
+package dawg
+
+import (
+	"bytes"
+	"container/heap"
+)
+
+// Completes keys whose prefix is within a given Levenshtein distance of a
+// query. Edit distance is measured in bytes of encoded keys.
+//
+// Dictionary nodes which approximately match the query are found with a
+// Levenshtein automaton walked over the guide. Every such node is completed
+// with its own RankedCompleter, and their results are merged through a single
+// priority queue, so keys come out in the order of the ranked guide.
+type FuzzyCompleter struct {
+	dict     *Dictionary
+	guide    *RankedGuide
+	maxEdits sizeType
+	penalty  valueType
+
+	query []ucharType
+	path  []ucharType
+	rows  []sizeType
+
+	completers  []*RankedCompleter
+	streams     []fuzzyStream
+	streamQueue fuzzyStreamQueue
+
+	key   []ucharType
+	value valueType
+	edits sizeType
+}
+
+// Completions of one approximately matching node. Streams are created in
+// preorder, so streams of descendant nodes go right after it up to end.
+type fuzzyStream struct {
+	completer *RankedCompleter
+	edits     sizeType
+	score     int64
+	end       int
+}
+
+// Creates a fuzzy completer. Scores need values ranked in ascending or
+// descending order, so guides of CustomOrder complete nothing.
+func NewFuzzyCompleter(dict *Dictionary, guide *RankedGuide, maxEdits sizeType) *FuzzyCompleter {
+	fc := &FuzzyCompleter{
+		dict:     dict,
+		guide:    guide,
+		maxEdits: maxEdits,
+	}
+	fc.streamQueue.completer = fc
+	return fc
+}
+
+// Sets a penalty which makes every edit worsen the value of a key.
+func (fc *FuzzyCompleter) SetPenalty(penalty valueType) {
+	fc.penalty = penalty
+}
+
+// These member functions are available only when Next() returns true. As with
+// other completers, Key ends with a zero byte which Length does not count.
+func (fc *FuzzyCompleter) Key() string {
+	return string(fc.key)
+}
+func (fc *FuzzyCompleter) Length() sizeType {
+	return len(fc.key) - 1
+}
+func (fc *FuzzyCompleter) Value() valueType {
+	return fc.value
+}
+func (fc *FuzzyCompleter) Value64() int64 {
+	return fc.dict.tableValue(fc.value)
+}
+
+// Number of edits between the query and the completed prefix of the key.
+func (fc *FuzzyCompleter) Edits() sizeType {
+	return fc.edits
+}
+
+// Starts completing keys which approximately match a given query.
+func (fc *FuzzyCompleter) Start(query string) {
+	fc.query = append(fc.query[:0], query...)
+	fc.path = fc.path[:0]
+	fc.streams = fc.streams[:0]
+	fc.streamQueue.items = fc.streamQueue.items[:0]
+
+	if fc.guide.size == 0 || fc.guide.Order() == CustomOrder {
+		return
+	}
+
+	var width sizeType = len(fc.query) + 1
+	if len(fc.rows) < width {
+		fc.rows = make([]sizeType, width)
+	}
+	for i := 0; i < width; i++ {
+		fc.rows[i] = i
+	}
+	fc.findNodes(fc.dict.Root(), 0, fc.maxEdits+1)
+
+	for i := range fc.streams {
+		if fc.streams[i].completer.Next() {
+			fc.updateScore(i)
+			fc.streamQueue.items = append(fc.streamQueue.items, i)
+		}
+	}
+	heap.Init(&fc.streamQueue)
+}
+
+// Gets the next key.
+func (fc *FuzzyCompleter) Next() bool {
+	for fc.streamQueue.Len() != 0 {
+		var i int = fc.streamQueue.items[0]
+		var completer *RankedCompleter = fc.streams[i].completer
+		fc.key = append(fc.key[:0], completer.path...)
+		fc.value = completer.Value()
+		fc.edits = fc.streams[i].edits
+
+		if completer.Next() {
+			fc.updateScore(i)
+			heap.Fix(&fc.streamQueue, 0)
+		} else {
+			heap.Pop(&fc.streamQueue)
+		}
+
+		if fc.isCompletedDeeper(i) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// Checks if the current key is also completed by a stream of a descendant
+// node. Such a stream has fewer edits, so the key belongs to it.
+func (fc *FuzzyCompleter) isCompletedDeeper(i int) bool {
+	for j := i + 1; j < fc.streams[i].end; j++ {
+		var completer *RankedCompleter = fc.streams[j].completer
+		if bytes.HasPrefix(fc.key, completer.path[:completer.prefixLength]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Finds up to k best completions of a given query, see RankedCompleter.TopK.
+func (fc *FuzzyCompleter) TopK(query string, k sizeType, dst []Result) []Result {
+	dst = dst[:0]
+	if k <= 0 {
+		return dst
+	}
+
+	fc.Start(query)
+	for len(dst) < k && fc.Next() {
+		dst = appendResult(dst, fc.key[:fc.Length()], fc.Value64())
+	}
+	return dst
+}
+
+// Walks the dictionary and creates streams for nodes that are close enough to
+// the query. A node is skipped if one of its ancestors is at least as close.
+func (fc *FuzzyCompleter) findNodes(index baseType, depth sizeType, bestEdits sizeType) {
+	var width sizeType = len(fc.query) + 1
+	var row []sizeType = fc.rows[depth*width : (depth+1)*width]
+
+	var stream int = -1
+	var edits sizeType = row[width-1]
+	if edits < bestEdits {
+		stream = len(fc.streams)
+		fc.addStream(index, edits)
+		bestEdits = edits
+	}
+
+	var minEdits sizeType = row[0]
+	for _, e := range row {
+		if e < minEdits {
+			minEdits = e
+		}
+	}
+	if minEdits >= bestEdits {
+		return
+	}
+
+	if len(fc.rows) < (depth+2)*width {
+		fc.rows = append(fc.rows, make([]sizeType, width)...)
+	}
+
+	var hasTerminal bool = fc.dict.HasValue(index)
+	var label ucharType = fc.guide.Child(index)
+	for {
+		if label == 0 {
+			if !hasTerminal {
+				break
+			}
+			hasTerminal = false
+		}
+
+		var childIndex baseType = index ^ fc.dict.offset(index) ^ baseType(label)
+		if label != 0 {
+			// Computes the next row of the automaton.
+			row = fc.rows[depth*width : (depth+1)*width]
+			var nextRow []sizeType = fc.rows[(depth+1)*width : (depth+2)*width]
+			nextRow[0] = depth + 1
+			for i := 1; i < width; i++ {
+				var cost sizeType = row[i-1]
+				if fc.query[i-1] != label {
+					cost++
+				}
+				if row[i]+1 < cost {
+					cost = row[i] + 1
+				}
+				if nextRow[i-1]+1 < cost {
+					cost = nextRow[i-1] + 1
+				}
+				nextRow[i] = cost
+			}
+
+			fc.path = append(fc.path, label)
+			fc.findNodes(childIndex, depth+1, bestEdits)
+			fc.path = fc.path[:len(fc.path)-1]
+		}
+
+		label = fc.guide.Sibling(childIndex)
+	}
+
+	if stream >= 0 {
+		fc.streams[stream].end = len(fc.streams)
+	}
+}
+
+// Creates a stream completing a given node.
+func (fc *FuzzyCompleter) addStream(index baseType, edits sizeType) {
+	var i int = len(fc.streams)
+	if i == len(fc.completers) {
+		fc.completers = append(fc.completers, NewRankedCompleter(fc.dict, fc.guide))
+	}
+	fc.completers[i].StartString(index, string(fc.path))
+	fc.streams = append(fc.streams, fuzzyStream{
+		completer: fc.completers[i],
+		edits:     edits,
+		end:       i + 1,
+	})
+}
+
+// Applies the penalty to the current value of a stream.
+func (fc *FuzzyCompleter) updateScore(i int) {
+	var penalty int64 = int64(fc.streams[i].edits) * int64(fc.penalty)
+	if fc.guide.Order() == AscendingOrder {
+		penalty = -penalty
+	}
+	fc.streams[i].score = fc.streams[i].completer.Value64() - penalty
+}
+
+// A priority queue of streams, the stream with the best score is on top.
+type fuzzyStreamQueue struct {
+	completer *FuzzyCompleter
+	items     []int
+}
+
+func (q *fuzzyStreamQueue) Len() int {
+	return len(q.items)
+}
+
+func (q *fuzzyStreamQueue) Less(i, j int) bool {
+	var lhs *fuzzyStream = &q.completer.streams[q.items[i]]
+	var rhs *fuzzyStream = &q.completer.streams[q.items[j]]
+	if lhs.score != rhs.score {
+		if q.completer.guide.Order() == AscendingOrder {
+			return lhs.score < rhs.score
+		}
+		return lhs.score > rhs.score
+	}
+	if lhs.edits != rhs.edits {
+		return lhs.edits < rhs.edits
+	}
+	return q.items[i] < q.items[j]
+}
+
+func (q *fuzzyStreamQueue) Swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+}
+
+func (q *fuzzyStreamQueue) Push(x interface{}) {
+	q.items = append(q.items, x.(int))
+}
+
+func (q *fuzzyStreamQueue) Pop() interface{} {
+	var last int = len(q.items) - 1
+	item := q.items[last]
+	q.items = q.items[:last]
+	return item
+}
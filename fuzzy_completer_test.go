@@ -0,0 +1,113 @@
+package dawg
+
+import "testing"
+
+func levenshtein(a string, b string) int {
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := prev
+			if a[i-1] != b[j-1] {
+				cost++
+			}
+			if row[j]+1 < cost {
+				cost = row[j] + 1
+			}
+			if row[j-1]+1 < cost {
+				cost = row[j-1] + 1
+			}
+			prev, row[j] = row[j], cost
+		}
+	}
+	return row[len(b)]
+}
+
+func TestFuzzyCompleter(t *testing.T) {
+	dawg, dict, lexicon := buildTestLexicon(t)
+	guide := BuildRankedGuide(dawg, dict)
+
+	for _, query := range []string{"bimd", "cn", "aple", "xyz", ""} {
+		for maxEdits := 0; maxEdits <= 2; maxEdits++ {
+			expected := map[string]int{}
+			for key := range lexicon {
+				for i := 0; i <= len(key); i++ {
+					edits := levenshtein(key[:i], query)
+					if best, ok := expected[key]; edits <= maxEdits && (!ok || edits < best) {
+						expected[key] = edits
+					}
+				}
+			}
+
+			completer := NewFuzzyCompleter(dict, guide, maxEdits)
+			completer.SetPenalty(10)
+			completer.Start(query)
+			count := 0
+			var prevScore int64 = MaxValue
+			for completer.Next() {
+				key := completer.Key()[:completer.Length()]
+				if completer.Length() != len(completer.Key())-1 || completer.Key()[completer.Length()] != 0 {
+					t.Errorf("Key %q does not end with a zero byte", completer.Key())
+				}
+				edits, ok := expected[key]
+				if !ok {
+					t.Errorf("%q (%d edits) completed to unexpected %q", query, maxEdits, key)
+				} else if edits != completer.Edits() {
+					t.Errorf("%q completed to %q with %d edits, expected %d", query, key, completer.Edits(), edits)
+				}
+				if completer.Value() != lexicon[key] {
+					t.Errorf("Key %q has value %d", key, completer.Value())
+				}
+				score := int64(completer.Value()) - 10*int64(completer.Edits())
+				if score > prevScore {
+					t.Errorf("%q completed to %q out of order", query, key)
+				}
+				prevScore = score
+				count++
+			}
+			if count != len(expected) {
+				t.Errorf("%q (%d edits) has %d completions, expected %d", query, maxEdits, count, len(expected))
+			}
+		}
+	}
+}
+
+func TestFuzzyCompleterSharedStates(t *testing.T) {
+	// Keys with equal values and suffixes end in the same dictionary units.
+	var keys = []string{"ba", "bat", "bats", "cat", "cats"}
+	builder := NewDawgBuilder()
+	for _, key := range keys {
+		builder.InsertStringValue(key, 1)
+	}
+	dawg := NewDawg()
+	builder.Finish(dawg)
+	dict := dawg.Build()
+	guide := BuildRankedGuide(dawg, dict)
+
+	completer := NewFuzzyCompleter(dict, guide, 1)
+	completer.Start("bat")
+	seen := map[string]bool{}
+	for completer.Next() {
+		key := completer.Key()[:completer.Length()]
+		if seen[key] {
+			t.Errorf("Key %q is completed twice", key)
+		}
+		seen[key] = true
+	}
+	if len(seen) != len(keys) {
+		t.Errorf("Unexpected completions %v", seen)
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		completer.Start("bat")
+		for completer.Next() {
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Completion allocates %v times", allocs)
+	}
+}
@@ -2,6 +2,7 @@ package dawg
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
 )
 
@@ -18,6 +19,21 @@ type SomeGuide interface {
 	Write(io.Writer) bool
 }
 
+// A guide which can check itself against a dictionary, as Guide and
+// RankedGuide do.
+type GuideValidator interface {
+	Validate(*Dictionary) error
+}
+
+// Checks a guide against a dictionary. Guides which do not implement
+// GuideValidator are not checked.
+func ValidateGuide(guide SomeGuide, dict *Dictionary) error {
+	if validator, ok := guide.(GuideValidator); ok {
+		return validator.Validate(dict)
+	}
+	return nil
+}
+
 type Guide struct {
 	units []GuideUnit
 	size  sizeType
@@ -57,6 +73,40 @@ func (guide *Guide) Sibling(index baseType) ucharType {
 	return guide.units[index].Sibling
 }
 
+// Checks that the guide lists every transition of a dictionary in ascending
+// order. Returns the first problem found.
+func (guide *Guide) Validate(dict *Dictionary) error {
+	units, err := dict.reachableUnits()
+	if err != nil {
+		return err
+	}
+	if guide.size != dict.size && !(guide.size == 0 && dict.isEmpty()) {
+		return fmt.Errorf("guide has %d units, dictionary has %d", guide.size, dict.size)
+	}
+	if guide.size == 0 {
+		return nil
+	}
+
+	var labels []ucharType
+	for _, index := range units {
+		labels = dict.appendChildLabels(index, labels[:0])
+
+		var label ucharType = guide.Child(index)
+		for _, expected := range labels {
+			if label != expected {
+				return fmt.Errorf("guide unit %d: label %d is listed instead of %d", index, label, expected)
+			}
+			var childIndex baseType = index
+			dict.Follow(label, &childIndex)
+			label = guide.Sibling(childIndex)
+		}
+		if label != 0 {
+			return fmt.Errorf("guide unit %d: label %d is not a transition", index, label)
+		}
+	}
+	return nil
+}
+
 func ReadGuide(r io.Reader) *Guide {
 	guide := NewGuide()
 	if !guide.Read(r) {
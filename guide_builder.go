@@ -7,6 +7,7 @@ type GuideBuilder struct {
 
 	units        []GuideUnit
 	isFixedTable []ucharType // why not use bit pool instead?
+	observer     BuildObserver
 }
 
 func NewGuideBuilder(dawg *Dawg, dict *Dictionary, guide *Guide) *GuideBuilder {
@@ -25,20 +26,30 @@ func BuildGuide(dawg *Dawg, dict *Dictionary) *Guide {
 	return builder.guide
 }
 
+// Sets an observer which receives progress of building.
+func (gb *GuideBuilder) SetObserver(observer BuildObserver) {
+	gb.observer = observer
+}
+
 func (gb *GuideBuilder) Build() bool {
+	if gb.observer != nil {
+		gb.observer.OnPhaseStart(GuidePhase)
+	}
+
 	// Initializes units and flags.
 	gb.units = make([]GuideUnit, gb.dict.size)
 	gb.isFixedTable = make([]ucharType, gb.dict.size/8)
 
-	if gb.dawg.Size() <= 1 {
-		return true
+	if gb.dawg.Size() > 1 {
+		if !gb.buildIndices(gb.dawg.Root(), gb.dict.Root()) {
+			return false
+		}
+		gb.guide.setUnits(gb.units)
 	}
 
-	if !gb.buildIndices(gb.dawg.Root(), gb.dict.Root()) {
-		return false
+	if gb.observer != nil {
+		gb.observer.OnPhaseEnd(&BuildStats{Phase: GuidePhase, NumOfUnits: gb.guide.Size()})
 	}
-
-	gb.guide.setUnits(gb.units)
 	return true
 }
 
@@ -2,6 +2,7 @@ package dawg
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
 )
 
@@ -42,6 +43,38 @@ func (index *Index) TotalCount() baseType {
 	return baseType(index.units[0])
 }
 
+// Checks that counts of keys agree with a dictionary and a guide. Returns the
+// first problem found.
+func (index *Index) Validate(dict *Dictionary, guide SomeGuide) error {
+	err := ValidateGuide(guide, dict)
+	if err != nil {
+		return err
+	}
+	units, _ := dict.reachableUnits()
+	if len(index.units) != dict.size {
+		return fmt.Errorf("index has %d units, dictionary has %d", len(index.units), dict.size)
+	}
+
+	for _, i := range units {
+		var count baseType = 0
+		var hasTerminal bool
+		label, ok := dict.firstGuideLabel(guide, i, &hasTerminal)
+		for ; ok; label, ok = dict.nextGuideLabel(guide, i, label, &hasTerminal) {
+			if label == 0 {
+				count++
+				continue
+			}
+			var childIndex baseType = i
+			dict.Follow(label, &childIndex)
+			count += index.ChildCount(childIndex)
+		}
+		if index.ChildCount(i) != count {
+			return fmt.Errorf("index unit %d: %d keys are counted instead of %d", i, index.ChildCount(i), count)
+		}
+	}
+	return nil
+}
+
 func ReadIndex(r io.Reader) *Index {
 	index := NewIndex()
 	if !index.Read(r) {
@@ -1,9 +1,11 @@
 package dawg
 
 type IndexBuilder struct {
-	dict  *Dictionary
-	guide SomeGuide
-	index *Index
+	dict       *Dictionary
+	guide      SomeGuide
+	index      *Index
+	aggregates *AggregateIndex
+	observer   BuildObserver
 }
 
 func NewIndexBuilder(dict *Dictionary, guide SomeGuide, index *Index) *IndexBuilder {
@@ -22,18 +24,65 @@ func BuildIndex(dict *Dictionary, guide SomeGuide) *Index {
 	return builder.index
 }
 
+// Builds an index along with aggregates of values.
+func BuildAggregateIndex(dict *Dictionary, guide SomeGuide) (*Index, *AggregateIndex) {
+	builder := NewIndexBuilder(dict, guide, &Index{})
+	builder.SetAggregates(&AggregateIndex{})
+	if !builder.Build() {
+		return nil, nil
+	}
+	return builder.index, builder.aggregates
+}
+
+// Makes the builder also fill an aggregate index.
+func (ib *IndexBuilder) SetAggregates(aggregates *AggregateIndex) {
+	ib.aggregates = aggregates
+}
+
+// Sets an observer which receives progress of building.
+func (ib *IndexBuilder) SetObserver(observer BuildObserver) {
+	ib.observer = observer
+}
+
 func (ib *IndexBuilder) Build() bool {
-	ib.index.units = make([]IndexUnit, len(ib.dict.units))
-	return ib.buildIndices(ib.guide.Root())
+	if ib.observer != nil {
+		ib.observer.OnPhaseStart(IndexPhase)
+	}
+
+	ib.index.units = make([]IndexUnit, ib.dict.size)
+	if ib.aggregates != nil {
+		ib.aggregates.units = make([]AggregateUnit, ib.dict.size)
+	}
+	if !ib.buildIndices(ib.guide.Root()) {
+		return false
+	}
+
+	if ib.observer != nil {
+		ib.observer.OnPhaseEnd(&BuildStats{Phase: IndexPhase, NumOfUnits: ib.index.Size()})
+	}
+	return true
 }
 
 func (ib *IndexBuilder) buildIndices(index baseType) bool {
+	var aggregate *AggregateUnit
+	if ib.aggregates != nil {
+		aggregate = &ib.aggregates.units[index]
+		aggregate.clear()
+	}
+
 	if ib.dict.HasValue(index) {
 		ib.index.units[index]++
+		if aggregate != nil {
+			aggregate.add(ib.dict.Value64(index))
+		}
 	}
 
-	var child ucharType = ib.guide.Child(index)
-	for child != 0 {
+	var hasTerminal bool
+	child, ok := ib.dict.firstGuideLabel(ib.guide, index, &hasTerminal)
+	for ; ok; child, ok = ib.dict.nextGuideLabel(ib.guide, index, child, &hasTerminal) {
+		if child == 0 {
+			continue
+		}
 		var childIndex baseType = index
 		if !ib.dict.Follow(child, &childIndex) {
 			return false
@@ -44,7 +93,9 @@ func (ib *IndexBuilder) buildIndices(index baseType) bool {
 			}
 		}
 		ib.index.units[index] += ib.index.units[childIndex]
-		child = ib.guide.Sibling(childIndex)
+		if aggregate != nil {
+			aggregate.merge(&ib.aggregates.units[childIndex])
+		}
 	}
 
 	return true
@@ -1,9 +1,12 @@
 package dawg
 
+import "math/rand"
+
 type Indexer struct {
-	dict  *Dictionary
-	guide SomeGuide
-	index *Index
+	dict       *Dictionary
+	guide      SomeGuide
+	index      *Index
+	aggregates *AggregateIndex
 }
 
 func NewIndexer(dict *Dictionary, guide SomeGuide, index *Index) *Indexer {
@@ -14,6 +17,23 @@ func NewIndexer(dict *Dictionary, guide SomeGuide, index *Index) *Indexer {
 	}
 }
 
+func NewIndexerWithAggregates(dict *Dictionary, guide SomeGuide, index *Index, aggregates *AggregateIndex) *Indexer {
+	return &Indexer{
+		dict:       dict,
+		guide:      guide,
+		index:      index,
+		aggregates: aggregates,
+	}
+}
+
+// Statistics of values of keys with a common prefix.
+type PrefixStats struct {
+	Count baseType
+	Sum   int64
+	Max   int64
+	Min   int64
+}
+
 const NotFound baseType = 0xffffffff
 const Failed baseType = 0xfffffffe
 
@@ -31,73 +51,306 @@ func (idx *Indexer) IndexToString(i baseType) string {
 
 func (idx *Indexer) BytesToIndex(bytes []ucharType) baseType {
 	var index baseType = idx.dict.Root()
-	var result baseType = 0
-	for i := 0; i < len(bytes); i++ {
-		if idx.dict.HasValue(index) {
-			result++
+	var result baseType = idx.findPrefix(bytes, &index)
+	if result == NotFound || result == Failed {
+		return result
+	}
+
+	if !idx.dict.HasValue(index) {
+		return NotFound
+	}
+
+	// Keys below the unit may precede its own key in a ranked guide.
+	var hasTerminal bool
+	label, ok := idx.dict.firstGuideLabel(idx.guide, index, &hasTerminal)
+	for ; ok && label != 0; label, ok = idx.dict.nextGuideLabel(idx.guide, index, label, &hasTerminal) {
+		var childIndex baseType = index
+		if !idx.dict.Follow(label, &childIndex) {
+			return Failed
 		}
+		result += idx.index.ChildCount(childIndex)
+	}
+	return result
+}
+
+func (idx *Indexer) IndexToBytes(i baseType) []ucharType {
+	return idx.selectKey(idx.dict.Root(), i, make([]ucharType, 0))
+}
 
-		var childLabel ucharType = idx.guide.Child(index)
-		for childLabel < bytes[i] && childLabel != 0 {
+// Number of keys starting with a given prefix.
+func (idx *Indexer) CountPrefix(prefix string) baseType {
+	var index baseType = idx.dict.Root()
+	if !idx.dict.FollowString(prefix, &index) {
+		return 0
+	}
+	return idx.index.ChildCount(index)
+}
+
+// Gets statistics of values of keys starting with a given prefix. Requires
+// an aggregate index, returns false without it or if there are no such keys.
+func (idx *Indexer) PrefixStats(prefix string) (PrefixStats, bool) {
+	var index baseType = idx.dict.Root()
+	if idx.aggregates == nil || !idx.dict.FollowString(prefix, &index) {
+		return PrefixStats{}, false
+	}
+	return PrefixStats{
+		Count: idx.index.ChildCount(index),
+		Sum:   idx.aggregates.Sum(index),
+		Max:   idx.aggregates.Max(index),
+		Min:   idx.aggregates.Min(index),
+	}, true
+}
+
+// Finds indices of the first and the last keys starting with a given prefix.
+// Keys with a common prefix always have contiguous indices.
+func (idx *Indexer) PrefixRange(prefix string) (baseType, baseType) {
+	var index baseType = idx.dict.Root()
+	var first baseType = idx.findPrefix(([]ucharType)(prefix), &index)
+	if first == NotFound || first == Failed {
+		return first, first
+	}
+	return first, first + idx.index.ChildCount(index) - 1
+}
+
+// Finds the k-th key (counting from zero) among keys with a given prefix.
+func (idx *Indexer) SelectInPrefix(prefix string, k baseType) (string, bool) {
+	var index baseType = idx.dict.Root()
+	if !idx.dict.FollowString(prefix, &index) || k >= idx.index.ChildCount(index) {
+		return "", false
+	}
+
+	var buf []ucharType = idx.selectKey(index, k, ([]ucharType)(prefix))
+	if buf == nil {
+		return "", false
+	}
+	return string(buf), true
+}
+
+// Calls fn for keys with indices from `from` (inclusive) to `to` (exclusive)
+// in the order of the guide until it returns false. Seeks to the first key
+// once and then advances along the guide. The key passed to fn is valid only
+// during the call. Returns false if the index is inconsistent with the
+// dictionary.
+func (idx *Indexer) Iterate(from baseType, to baseType, fn func(i baseType, key []byte, value int64) bool) bool {
+	if to > idx.TotalCount() {
+		to = idx.TotalCount()
+	}
+	if from >= to {
+		return true
+	}
+
+	var c indexerCursor
+	if !idx.seekKey(&c, from) {
+		return false
+	}
+
+	for i := from; i < to; i++ {
+		if i != from && !idx.nextKey(&c) {
+			return false
+		}
+		var index baseType = c.frames[len(c.frames)-1].index
+		if !fn(i, c.key, idx.dict.Value64(index)) {
+			break
+		}
+	}
+	return true
+}
+
+// Path from the root to a key, with the label followed at each unit, where a
+// zero label is the end of the key.
+type indexerCursor struct {
+	frames []indexerFrame
+	key    []ucharType
+}
+
+type indexerFrame struct {
+	index       baseType
+	label       ucharType
+	hasTerminal bool
+}
+
+// Positions a cursor at the i-th key.
+func (idx *Indexer) seekKey(c *indexerCursor, i baseType) bool {
+	var index baseType = idx.dict.Root()
+	var cur baseType = 0
+	for {
+		var frame indexerFrame = indexerFrame{index: index}
+		label, ok := idx.dict.firstGuideLabel(idx.guide, index, &frame.hasTerminal)
+		for ; ok; label, ok = idx.dict.nextGuideLabel(idx.guide, index, label, &frame.hasTerminal) {
+			if label == 0 {
+				if cur == i {
+					break
+				}
+				cur++
+				continue
+			}
 			var childIndex baseType = index
-			if !idx.dict.Follow(childLabel, &childIndex) {
-				return Failed
+			if !idx.dict.Follow(label, &childIndex) {
+				return false
 			}
-			result += idx.index.ChildCount(childIndex)
-			childLabel = idx.guide.Sibling(childIndex)
+			var count baseType = idx.index.ChildCount(childIndex)
+			if i < cur+count {
+				break
+			}
+			cur += count
+		}
+		if !ok {
+			return false
 		}
 
-		if childLabel == 0 {
-			return NotFound
+		frame.label = label
+		c.frames = append(c.frames, frame)
+		if label == 0 {
+			return true
 		}
+		c.key = append(c.key, label)
+		idx.dict.Follow(label, &index)
+	}
+}
 
-		var childIndex baseType = index
-		if !idx.dict.Follow(bytes[i], &childIndex) {
-			return NotFound
+// Moves a cursor to the next key.
+func (idx *Indexer) nextKey(c *indexerCursor) bool {
+	for len(c.frames) != 0 {
+		var frame *indexerFrame = &c.frames[len(c.frames)-1]
+		label, ok := idx.dict.nextGuideLabel(idx.guide, frame.index, frame.label, &frame.hasTerminal)
+		if !ok {
+			c.frames = c.frames[:len(c.frames)-1]
+			if len(c.frames) != 0 {
+				c.key = c.key[:len(c.key)-1]
+			}
+			continue
 		}
-		index = childIndex
+		frame.label = label
+
+		// Descends to the first key below the label.
+		for label != 0 {
+			var childIndex baseType = frame.index
+			if !idx.dict.Follow(label, &childIndex) {
+				return false
+			}
+			c.key = append(c.key, label)
+			c.frames = append(c.frames, indexerFrame{index: childIndex})
+			frame = &c.frames[len(c.frames)-1]
+			if label, ok = idx.dict.firstGuideLabel(idx.guide, childIndex, &frame.hasTerminal); !ok {
+				return false
+			}
+			frame.label = label
+		}
+		return true
 	}
+	return false
+}
 
-	if !idx.dict.HasValue(index) {
-		return NotFound
+// Picks a uniformly random key.
+func (idx *Indexer) Sample(rng *rand.Rand) (string, bool) {
+	var total baseType = idx.TotalCount()
+	if total == 0 {
+		return "", false
 	}
 
-	return result
+	var buf []ucharType = idx.selectKey(idx.dict.Root(), baseType(rng.Int63n(int64(total))), nil)
+	if buf == nil {
+		return "", false
+	}
+	return string(buf), true
 }
 
-func (idx *Indexer) IndexToBytes(i baseType) []ucharType {
+// Picks a random key with probability proportional to its value, descending
+// by sums of values in subtrees. Requires an aggregate index and non-negative
+// values.
+func (idx *Indexer) SampleWeighted(rng *rand.Rand) (string, bool) {
 	var index baseType = idx.dict.Root()
-	var cur baseType = 0
-	var buf []ucharType = make([]ucharType, 0)
-	for cur <= i {
-		if idx.dict.HasValue(index) {
-			if cur == i {
-				return buf
+	if idx.aggregates == nil || idx.aggregates.Sum(index) <= 0 {
+		return "", false
+	}
+
+	var weight int64 = rng.Int63n(idx.aggregates.Sum(index))
+	var buf []ucharType
+	for {
+		var hasTerminal bool
+		label, ok := idx.dict.firstGuideLabel(idx.guide, index, &hasTerminal)
+		for ; ok; label, ok = idx.dict.nextGuideLabel(idx.guide, index, label, &hasTerminal) {
+			if label == 0 {
+				var value int64 = idx.dict.Value64(index)
+				if weight < value {
+					return string(buf), true
+				}
+				weight -= value
+				continue
+			}
+			var childIndex baseType = index
+			if !idx.dict.Follow(label, &childIndex) {
+				return "", false
 			}
-			cur++
+			var sum int64 = idx.aggregates.Sum(childIndex)
+			if weight < sum {
+				buf = append(buf, label)
+				index = childIndex
+				break
+			}
+			weight -= sum
+		}
+
+		if !ok {
+			return "", false
 		}
+	}
+}
 
-		var childLabel ucharType = idx.guide.Child(index)
-		for childLabel != 0 {
+// Follows a prefix and counts keys preceding it.
+func (idx *Indexer) findPrefix(bytes []ucharType, index *baseType) baseType {
+	var result baseType = 0
+	for i := 0; i < len(bytes); i++ {
+		var hasTerminal bool
+		label, ok := idx.dict.firstGuideLabel(idx.guide, *index, &hasTerminal)
+		for ; ok && (label != bytes[i] || label == 0); label, ok = idx.dict.nextGuideLabel(idx.guide, *index, label, &hasTerminal) {
+			if label == 0 {
+				result++
+				continue
+			}
+			var childIndex baseType = *index
+			if !idx.dict.Follow(label, &childIndex) {
+				return Failed
+			}
+			result += idx.index.ChildCount(childIndex)
+		}
+
+		if !ok || !idx.dict.Follow(bytes[i], index) {
+			return NotFound
+		}
+	}
+	return result
+}
+
+// Appends the i-th key below a given node to a buffer.
+func (idx *Indexer) selectKey(index baseType, i baseType, buf []ucharType) []ucharType {
+	var cur baseType = 0
+	for {
+		var hasTerminal bool
+		label, ok := idx.dict.firstGuideLabel(idx.guide, index, &hasTerminal)
+		for ; ok; label, ok = idx.dict.nextGuideLabel(idx.guide, index, label, &hasTerminal) {
+			if label == 0 {
+				if cur == i {
+					return buf
+				}
+				cur++
+				continue
+			}
 			var childIndex baseType = index
-			if !idx.dict.Follow(childLabel, &childIndex) {
+			if !idx.dict.Follow(label, &childIndex) {
 				return nil
 			}
 			var count baseType = idx.index.ChildCount(childIndex)
 			if i < cur+count {
-				buf = append(buf, childLabel)
+				buf = append(buf, label)
 				index = childIndex
 				break
-			} else {
-				cur += count
-				childLabel = idx.guide.Sibling(childIndex)
 			}
+			cur += count
 		}
 
-		if childLabel == 0 {
+		if !ok {
 			return nil
 		}
 	}
-
-	return nil
 }
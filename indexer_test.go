@@ -0,0 +1,181 @@
+package dawg
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestIndexerPrefix(t *testing.T) {
+	dawg, dict, lexicon := buildTestLexicon(t)
+	guide := BuildGuide(dawg, dict)
+	indexer := NewIndexer(dict, guide, BuildIndex(dict, guide))
+	keys := sortedTestKeys(lexicon)
+
+	for _, prefix := range []string{"", "a", "ap", "bin", "binder", "c", "ca", "x"} {
+		var first, count baseType = NotFound, 0
+		for i, key := range keys {
+			if strings.HasPrefix(key, prefix) {
+				if count == 0 {
+					first = baseType(i)
+				}
+				count++
+			}
+		}
+
+		if indexer.CountPrefix(prefix) != count {
+			t.Errorf("CountPrefix(%q) = %d, expected %d", prefix, indexer.CountPrefix(prefix), count)
+		}
+
+		lo, hi := indexer.PrefixRange(prefix)
+		if lo != first || (count != 0 && hi != first+count-1) {
+			t.Errorf("PrefixRange(%q) = %d, %d, expected %d, %d", prefix, lo, hi, first, first+count-1)
+		}
+
+		for k := baseType(0); k <= count; k++ {
+			key, ok := indexer.SelectInPrefix(prefix, k)
+			if ok != (k < count) || (ok && key != keys[first+k]) {
+				t.Errorf("SelectInPrefix(%q, %d) = %q, %v", prefix, k, key, ok)
+			}
+		}
+	}
+}
+
+func TestIndexerIterate(t *testing.T) {
+	dawg, dict, lexicon := buildTestLexicon(t)
+	guide := BuildGuide(dawg, dict)
+	indexer := NewIndexer(dict, guide, BuildIndex(dict, guide))
+	keys := sortedTestKeys(lexicon)
+
+	for from := 0; from <= len(keys); from++ {
+		to := from + 5
+		count := 0
+		ok := indexer.Iterate(baseType(from), baseType(to), func(i baseType, key []byte, value int64) bool {
+			if int(i) != from+count || string(key) != keys[i] || value != int64(lexicon[keys[i]]) {
+				t.Errorf("Iterate(%d, %d) yields %d: %q = %d", from, to, i, key, value)
+			}
+			count++
+			return true
+		})
+		if !ok {
+			t.Errorf("Iterate(%d, %d) failed", from, to)
+		}
+		if to > len(keys) {
+			to = len(keys)
+		}
+		if count != to-from {
+			t.Errorf("Iterate(%d, %d) yields %d keys", from, to, count)
+		}
+	}
+}
+
+func TestIndexerPrefixStats(t *testing.T) {
+	dawg, dict, lexicon := buildTestLexicon(t)
+	guide := BuildGuide(dawg, dict)
+	index, aggregates := BuildAggregateIndex(dict, guide)
+	indexer := NewIndexerWithAggregates(dict, guide, index, aggregates)
+
+	for _, prefix := range []string{"", "a", "bin", "ca"} {
+		var expected PrefixStats
+		for key, value := range lexicon {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			if expected.Count == 0 || int64(value) > expected.Max {
+				expected.Max = int64(value)
+			}
+			if expected.Count == 0 || int64(value) < expected.Min {
+				expected.Min = int64(value)
+			}
+			expected.Sum += int64(value)
+			expected.Count++
+		}
+
+		stats, ok := indexer.PrefixStats(prefix)
+		if !ok || stats != expected {
+			t.Errorf("PrefixStats(%q) = %+v, expected %+v", prefix, stats, expected)
+		}
+	}
+
+	if _, ok := indexer.PrefixStats("x"); ok {
+		t.Errorf("Stats of a missing prefix are found")
+	}
+}
+
+func TestIndexerSample(t *testing.T) {
+	dawg, dict, lexicon := buildTestLexicon(t)
+	guide := BuildGuide(dawg, dict)
+	index, aggregates := BuildAggregateIndex(dict, guide)
+	indexer := NewIndexerWithAggregates(dict, guide, index, aggregates)
+	rng := rand.New(rand.NewSource(1))
+
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		key, ok := indexer.Sample(rng)
+		if _, found := lexicon[key]; !ok || !found {
+			t.Fatalf("Sample() = %q, %v", key, ok)
+		}
+		counts[key]++
+	}
+	if len(counts) != len(lexicon) {
+		t.Errorf("Sampled %d distinct keys out of %d", len(counts), len(lexicon))
+	}
+
+	for i := 0; i < 1000; i++ {
+		key, ok := indexer.SampleWeighted(rng)
+		if value, found := lexicon[key]; !ok || !found || value == 0 {
+			t.Fatalf("SampleWeighted() = %q, %v", key, ok)
+		}
+	}
+}
+
+// Appends keys below a unit in the order of a guide.
+func appendGuideKeys(dict *Dictionary, guide SomeGuide, index baseType, prefix string, keys []string) []string {
+	for _, label := range dict.AppendGuideLabels(guide, index, nil) {
+		if label == 0 {
+			keys = append(keys, prefix)
+			continue
+		}
+		var childIndex baseType = index
+		dict.Follow(label, &childIndex)
+		keys = appendGuideKeys(dict, guide, childIndex, prefix+string(rune(label)), keys)
+	}
+	return keys
+}
+
+func TestIndexerGuideOrder(t *testing.T) {
+	dawg, dict, lexicon := buildTestLexiconWithEmptyKey(t)
+	for _, guide := range []SomeGuide{BuildGuide(dawg, dict), BuildRankedGuide(dawg, dict)} {
+		indexer := NewIndexer(dict, guide, BuildIndex(dict, guide))
+		keys := appendGuideKeys(dict, guide, dict.Root(), "", nil)
+		if _, ok := guide.(*Guide); ok && strings.Join(keys, " ") != strings.Join(sortedTestKeys(lexicon), " ") {
+			t.Fatalf("Guide lists keys out of order: %q", keys)
+		}
+		if len(keys) != len(lexicon) || indexer.TotalCount() != baseType(len(keys)) {
+			t.Fatalf("%T: %d keys are listed and %d are counted, expected %d", guide, len(keys), indexer.TotalCount(), len(lexicon))
+		}
+
+		for from := 0; from < len(keys); from += 3 {
+			var actual []string
+			ok := indexer.Iterate(baseType(from), NotFound, func(i baseType, key []byte, value int64) bool {
+				if value != int64(lexicon[string(key)]) {
+					t.Errorf("%T: key %q has value %d", guide, key, value)
+				}
+				actual = append(actual, string(key))
+				return true
+			})
+			if !ok || strings.Join(actual, " ") != strings.Join(keys[from:], " ") {
+				t.Fatalf("%T: Iterate(%d) yields %q, expected %q", guide, from, actual, keys[from:])
+			}
+		}
+
+		for i, key := range keys {
+			if indexer.StringToIndex(key) != baseType(i) || indexer.IndexToString(baseType(i)) != key {
+				t.Errorf("%T: key %q is not at %d", guide, key, i)
+			}
+		}
+		if lo, hi := indexer.PrefixRange("bin"); indexer.IndexToString(lo)[:3] != "bin" || hi-lo+1 != indexer.CountPrefix("bin") {
+			t.Errorf("%T: PrefixRange(\"bin\") = %d, %d", guide, lo, hi)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package dawg
+
+// Iterates over keys of a dictionary in ascending order. Children of units
+// are found by a guide if it is given, or by following all labels otherwise.
+type KeyIterator struct {
+	dict  *Dictionary
+	guide *Guide
+
+	key     []ucharType
+	indices []baseType
+	// Labels followed last at each depth, where 0 means that no child is
+	// followed yet and -1 that a value is not visited yet.
+	labels []int
+	value  int64
+}
+
+func NewKeyIterator(dict *Dictionary, guide *Guide) *KeyIterator {
+	it := &KeyIterator{dict: dict}
+	if guide != nil && guide.Size() != 0 {
+		it.guide = guide
+	}
+	if dict.Size() != 0 {
+		it.indices = append(it.indices, dict.Root())
+		it.labels = append(it.labels, -1)
+	}
+	return it
+}
+
+// Gets the current key, which is valid until the next call of Next.
+func (it *KeyIterator) Key() []byte {
+	return it.key
+}
+
+func (it *KeyIterator) Value64() int64 {
+	return it.value
+}
+
+// Finds the next child of a unit after a given label.
+func (it *KeyIterator) nextChild(index baseType, label int) (ucharType, baseType, bool) {
+	if it.guide != nil {
+		var childLabel ucharType
+		if label == 0 {
+			childLabel = it.guide.Child(index)
+		} else {
+			var childIndex baseType = index
+			it.dict.Follow(ucharType(label), &childIndex)
+			childLabel = it.guide.Sibling(childIndex)
+		}
+		var childIndex baseType = index
+		if childLabel == 0 || !it.dict.Follow(childLabel, &childIndex) {
+			return 0, 0, false
+		}
+		return childLabel, childIndex, true
+	}
+
+	for label++; label < numOfLabels; label++ {
+		var childIndex baseType = index
+		if it.dict.Follow(ucharType(label), &childIndex) {
+			return ucharType(label), childIndex, true
+		}
+	}
+	return 0, 0, false
+}
+
+// Moves to the next key. Returns false after the last one.
+func (it *KeyIterator) Next() bool {
+	for len(it.indices) != 0 {
+		var depth int = len(it.indices) - 1
+		var index baseType = it.indices[depth]
+		if it.labels[depth] < 0 {
+			it.labels[depth] = 0
+			if it.dict.HasValue(index) {
+				it.value = it.dict.Value64(index)
+				return true
+			}
+		}
+
+		label, childIndex, ok := it.nextChild(index, it.labels[depth])
+		if !ok {
+			it.indices = it.indices[:depth]
+			it.labels = it.labels[:depth]
+			if depth > 0 {
+				it.key = it.key[:depth-1]
+			}
+			continue
+		}
+
+		it.labels[depth] = int(label)
+		it.key = append(it.key, label)
+		it.indices = append(it.indices, childIndex)
+		it.labels = append(it.labels, -1)
+	}
+	return false
+}
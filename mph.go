@@ -0,0 +1,112 @@
+package dawg
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// A minimal perfect hash mapping keys of a dictionary to [0, Size()) by their
+// indices, with sidecar columns holding arbitrary per-key values.
+type MPH struct {
+	indexer *Indexer
+	columns []*Column
+}
+
+func NewMPH(dict *Dictionary, guide SomeGuide, index *Index) *MPH {
+	return &MPH{
+		indexer: NewIndexer(dict, guide, index),
+	}
+}
+
+// Number of keys.
+func (m *MPH) Size() sizeType {
+	return sizeType(m.indexer.TotalCount())
+}
+
+// Finds the hash value of a key.
+func (m *MPH) Lookup(key string) (int, bool) {
+	return m.LookupBytes(([]ucharType)(key))
+}
+func (m *MPH) LookupBytes(key []ucharType) (int, bool) {
+	var i baseType = m.indexer.BytesToIndex(key)
+	if i == NotFound || i == Failed {
+		return 0, false
+	}
+	return int(i), true
+}
+
+// Adds a column with a value for every key. Returns nil if there is a column
+// with the same name.
+func (m *MPH) AddColumn(name string, kind ColumnType) *Column {
+	if m.Column(name) != nil {
+		return nil
+	}
+	col := NewColumn(name, kind, m.Size())
+	m.columns = append(m.columns, col)
+	return col
+}
+
+// Finds a column by its name.
+func (m *MPH) Column(name string) *Column {
+	for _, col := range m.columns {
+		if col.name == name {
+			return col
+		}
+	}
+	return nil
+}
+
+func (m *MPH) Columns() []*Column {
+	return m.columns
+}
+
+// Reads columns written after the dictionary, the guide and the index.
+func ReadMPH(r io.Reader, dict *Dictionary, guide SomeGuide, index *Index) *MPH {
+	m := NewMPH(dict, guide, index)
+	if !m.Read(r) {
+		return nil
+	}
+	return m
+}
+
+// Reads columns from an input stream.
+func (m *MPH) Read(r io.Reader) bool {
+	var numOfColumns baseType
+	err := binary.Read(r, binary.LittleEndian, &numOfColumns)
+	if err != nil {
+		return false
+	}
+
+	m.columns = nil
+	for i := baseType(0); i < numOfColumns; i++ {
+		col := ReadColumn(r)
+		if col == nil || col.size != m.Size() || m.Column(col.name) != nil {
+			return false
+		}
+		m.columns = append(m.columns, col)
+	}
+	return true
+}
+
+// Writes columns to an output stream. Fails if names of columns repeat.
+func (m *MPH) Write(w io.Writer) bool {
+	for i, col := range m.columns {
+		for _, prev := range m.columns[:i] {
+			if prev.name == col.name {
+				return false
+			}
+		}
+	}
+
+	err := binary.Write(w, binary.LittleEndian, baseType(len(m.columns)))
+	if err != nil {
+		return false
+	}
+
+	for _, col := range m.columns {
+		if !col.Write(w) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package dawg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"runtime"
+	"testing"
+)
+
+func TestMPHColumns(t *testing.T) {
+	dawg, dict, lexicon := buildTestLexicon(t)
+	guide := BuildGuide(dawg, dict)
+	index := BuildIndex(dict, guide)
+
+	m := NewMPH(dict, guide, index)
+	if m.Size() != len(lexicon) {
+		t.Fatalf("MPH has %d keys, expected %d", m.Size(), len(lexicon))
+	}
+	if _, ok := m.Lookup("appl"); ok {
+		t.Errorf("Missing key is found")
+	}
+
+	signed := m.AddColumn("signed", Int16Column)
+	floats := m.AddColumn("floats", Float64Column)
+	blobs := m.AddColumn("blobs", BlobColumn)
+	for key := range lexicon {
+		i, ok := m.Lookup(key)
+		if !ok || i < 0 || i >= m.Size() {
+			t.Fatalf("Lookup(%q) = %d, %v", key, i, ok)
+		}
+		signed.SetInt(i, -int64(len(key)))
+		floats.SetFloat(i, float64(len(key))/2)
+		blobs.SetBlob(i, []byte(key))
+	}
+
+	var buf bytes.Buffer
+	if !m.Write(&buf) {
+		t.Fatal("Failed to write MPH")
+	}
+	loaded := ReadMPH(&buf, dict, guide, index)
+	if loaded == nil {
+		t.Fatal("Failed to read MPH")
+	}
+
+	for key := range lexicon {
+		i, _ := loaded.Lookup(key)
+		if v := loaded.Column("signed").Int(i); v != -int64(len(key)) {
+			t.Errorf("Key %q has signed value %d", key, v)
+		}
+		if v := loaded.Column("floats").Float(i); v != float64(len(key))/2 {
+			t.Errorf("Key %q has float value %v", key, v)
+		}
+		if v := loaded.Column("blobs").Blob(i); string(v) != key {
+			t.Errorf("Key %q has blob %q", key, v)
+		}
+	}
+}
+
+func TestMPHDuplicateColumns(t *testing.T) {
+	dawg, dict, _ := buildTestLexicon(t)
+	guide := BuildGuide(dawg, dict)
+	index := BuildIndex(dict, guide)
+
+	m := NewMPH(dict, guide, index)
+	col := m.AddColumn("count", Uint8Column)
+	if m.AddColumn("count", Int64Column) != nil {
+		t.Errorf("Column with a repeated name is added")
+	}
+
+	m.columns = append(m.columns, col)
+	var buf bytes.Buffer
+	if m.Write(&buf) {
+		t.Errorf("Columns with repeated names are written")
+	}
+
+	buf.Reset()
+	binary.Write(&buf, binary.LittleEndian, baseType(2))
+	col.Write(&buf)
+	col.Write(&buf)
+	if ReadMPH(&buf, dict, guide, index) != nil {
+		t.Errorf("Columns with repeated names are read")
+	}
+}
+
+func TestColumnReadCorrupt(t *testing.T) {
+	var header = func(kind ColumnType, nameLength baseType, size baseType) *bytes.Buffer {
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.LittleEndian, kind)
+		binary.Write(&buf, binary.LittleEndian, nameLength)
+		buf.WriteString("x")
+		binary.Write(&buf, binary.LittleEndian, size)
+		return &buf
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for _, buf := range []*bytes.Buffer{
+		header(Int64Column, 0xffffffff, 1),
+		header(Int64Column, 1, 0x7fffffff),
+		header(BlobColumn, 1, 0x7fffffff),
+	} {
+		if ReadColumn(buf) != nil {
+			t.Errorf("Corrupt column is read")
+		}
+	}
+	runtime.ReadMemStats(&after)
+	if after.TotalAlloc-before.TotalAlloc > 16<<20 {
+		t.Errorf("Reading corrupt columns allocates %d bytes", after.TotalAlloc-before.TotalAlloc)
+	}
+}
@@ -0,0 +1,402 @@
+package dawg
+
+import (
+	"bytes"
+	"runtime"
+	"sync"
+)
+
+// Number of bytes of keys after which the next shard is started.
+const defaultShardSize = 1 << 18
+
+// Builds a dawg from a range of keys. Keys of a shard share at least as many
+// labels with each other as its first key shares with the last key of the
+// previous shard, so states below those labels are only reached from the
+// shard.
+type dawgShard struct {
+	// Numbers of labels which are shared with the last key of the previous
+	// shard and with the first key of the next one.
+	prevLength sizeType
+	nextLength sizeType
+
+	keys   []ucharType
+	ends   []sizeType
+	values []valueType
+
+	// Values of shards which are started after a value table is enabled are
+	// ids of values in the table.
+	hasValueIds bool
+
+	// States which are fixed in the shard, and states on the path of its
+	// last key from the depth of prevLength, which are not fixed yet as they
+	// may get transitions of other shards.
+	states                 *dawgStates
+	path                   [][]dawgTransition
+	numOfMergedTransitions sizeType
+	ok                     bool
+}
+
+func (shard *dawgShard) insert(key []ucharType, value valueType) {
+	shard.keys = append(shard.keys, key...)
+	shard.ends = append(shard.ends, len(shard.keys))
+	shard.values = append(shard.values, value)
+}
+
+// Inserts buffered keys and fixes all states which are not shared with
+// other shards, as it happens when the first key of the next shard is
+// inserted.
+func (shard *dawgShard) build() {
+	builder := NewDawgBuilder()
+
+	var begin sizeType = 0
+	for i, end := range shard.ends {
+		if builder.insertKeyValue(shard.keys[begin:end], end-begin, shard.values[i]) != nil {
+			return
+		}
+		begin = end
+	}
+	shard.keys = nil
+	shard.ends = nil
+	shard.values = nil
+
+	var depth sizeType = shard.prevLength
+	if shard.nextLength > depth {
+		depth = shard.nextLength
+	}
+	builder.fixUnits(builder.unfixedUnits[depth+1])
+
+	// Numbers states in the order of fixing.
+	shard.states = newDawgStates()
+	var numbers []baseType = make([]baseType, len(builder.basePool))
+	var transitions []dawgTransition
+	for index := baseType(1); sizeType(index) < len(builder.basePool); index++ {
+		var first baseType = index
+		transitions = transitions[:0]
+		for {
+			var child baseType = baseType(builder.basePool[index].value())
+			var label ucharType = builder.labelPool[index]
+			if label != 0 {
+				child = numbers[builder.basePool[index].child()]
+			}
+			transitions = append(transitions, shard.states.transition(label, child))
+			if !builder.basePool[index].hasSibling() {
+				break
+			}
+			index++
+		}
+		numbers[first] = shard.states.add(transitions, builder.flagPool.get(first)).child
+	}
+
+	// Keeps unfixed states with transitions in the order of labels.
+	shard.path = make([][]dawgTransition, depth-shard.prevLength+1)
+	for i := depth; i >= shard.prevLength; i-- {
+		var unitIndex baseType = builder.unitPool[builder.unfixedUnits[depth]].child
+		if i < depth {
+			unitIndex = builder.unfixedUnits[i+1]
+		}
+
+		var state []dawgTransition
+		for j := unitIndex; j != 0; j = builder.unitPool[j].sibling {
+			var unit *DawgUnit = &builder.unitPool[j]
+			if unit.label == 0 || (j == unitIndex && i < depth) {
+				state = append(state, dawgTransition{label: unit.label, child: unit.child})
+			} else {
+				state = append(state, shard.states.transition(unit.label, numbers[unit.child]))
+			}
+		}
+		for l, r := 0, len(state)-1; l < r; l, r = l+1, r-1 {
+			state[l], state[r] = state[r], state[l]
+		}
+		shard.path[i-shard.prevLength] = state
+	}
+
+	shard.numOfMergedTransitions = builder.numOfMergedTransitions
+	shard.ok = true
+}
+
+// Replaces values of a shard which is started before a value table is
+// enabled with their ids.
+func (shard *dawgShard) useValueIds(valueId func(int64) valueType) {
+	var states *dawgStates = shard.states
+	for i, label := range states.labels {
+		if label == 0 {
+			states.children[i] = baseType(valueId(int64(states.children[i])))
+		}
+	}
+	states.rehash()
+
+	for i, state := range shard.path {
+		for j := range state {
+			if state[j].label == 0 {
+				state[j].child = baseType(valueId(int64(state[j].child)))
+			} else if j+1 < len(state) || i+1 == len(shard.path) {
+				state[j] = states.transition(state[j].label, state[j].child)
+			}
+		}
+	}
+	shard.hasValueIds = true
+}
+
+// Builds a dawg on several goroutines. Keys are split into ranges of about
+// the same size, each range is built on its own, and then equivalent states
+// of all ranges are merged in parallel, keeping the order in which a
+// DawgBuilder creates them, so that the resulting dawg is identical to the
+// one built from the same keys sequentially.
+type ParallelDawgBuilder struct {
+	workers    chan struct{}
+	group      sync.WaitGroup
+	shards     []*dawgShard
+	shardSize  sizeType
+	lastKey    []ucharType
+	values     []int64
+	valueIds   map[int64]valueType
+	valueTable bool
+
+	duplicatePolicy DuplicatePolicy
+	observer        BuildObserver
+	numOfKeys       sizeType
+	numOfDuplicates sizeType
+}
+
+// Creates a builder which uses up to a given number of goroutines, or
+// GOMAXPROCS goroutines if the number is not positive.
+func NewParallelDawgBuilder(numOfWorkers sizeType) *ParallelDawgBuilder {
+	if numOfWorkers <= 0 {
+		numOfWorkers = runtime.GOMAXPROCS(0)
+	}
+	return &ParallelDawgBuilder{
+		workers:   make(chan struct{}, numOfWorkers),
+		shardSize: defaultShardSize,
+	}
+}
+
+// Starts building the last shard.
+func (pb *ParallelDawgBuilder) startShard() {
+	if len(pb.shards) == 0 {
+		return
+	}
+	var shard *dawgShard = pb.shards[len(pb.shards)-1]
+
+	pb.workers <- struct{}{}
+	pb.group.Add(1)
+	go func() {
+		defer pb.group.Done()
+		shard.build()
+		<-pb.workers
+	}()
+}
+
+// Gets an id of a value from a value table.
+func (pb *ParallelDawgBuilder) valueId(value int64) valueType {
+	id, ok := pb.valueIds[value]
+	if !ok {
+		id = valueType(len(pb.values))
+		pb.values = append(pb.values, value)
+		pb.valueIds[value] = id
+	}
+	return id
+}
+
+// Sets an observer which receives progress of inserting keys. Shards and
+// merging them are not observed.
+func (pb *ParallelDawgBuilder) SetObserver(observer BuildObserver) {
+	pb.observer = observer
+}
+
+// Counts an inserted key and reports progress periodically.
+func (pb *ParallelDawgBuilder) countKey() {
+	if pb.observer != nil && pb.numOfKeys == 0 {
+		pb.observer.OnPhaseStart(DawgPhase)
+	}
+	pb.numOfKeys += 1
+	if pb.observer != nil && pb.numOfKeys%progressInterval == 0 {
+		pb.observer.OnProgress(&BuildStats{Phase: DawgPhase, NumOfKeys: pb.numOfKeys})
+	}
+}
+
+// Gets a value which is buffered as it is or as an id in a value table.
+func (pb *ParallelDawgBuilder) tableValue(value valueType) int64 {
+	if pb.values != nil {
+		return pb.values[value]
+	}
+	return int64(value)
+}
+
+// Sets what to do when the same key is inserted again. The last value is
+// kept by default.
+func (pb *ParallelDawgBuilder) SetDuplicatePolicy(policy DuplicatePolicy) {
+	pb.duplicatePolicy = policy
+}
+
+// Number of keys inserted again.
+func (pb *ParallelDawgBuilder) NumOfDuplicates() sizeType {
+	return pb.numOfDuplicates
+}
+
+// Combines a value of the last key with a value inserted again. Keys are
+// sorted, so the last key is always buffered in the last shard.
+func (pb *ParallelDawgBuilder) mergeDuplicate(value valueType) error {
+	pb.numOfDuplicates += 1
+	if pb.duplicatePolicy == DuplicateError {
+		return &DuplicateKeyError{Key: string(pb.lastKey)}
+	}
+
+	var shard *dawgShard = pb.shards[len(pb.shards)-1]
+	var last sizeType = len(shard.values) - 1
+	var merged int64 = pb.duplicatePolicy.merge(pb.tableValue(shard.values[last]), pb.tableValue(value))
+	if pb.values == nil {
+		if !pb.valueTable {
+			if merged < 0 || merged > MaxValue {
+				return ErrValueRange
+			}
+			shard.values[last] = valueType(merged)
+			return nil
+		}
+		pb.moveValuesToTable()
+	}
+	shard.values[last] = pb.valueId(merged)
+	return nil
+}
+
+// Makes the builder keep values in a value table, see
+// DawgBuilder.UseValueTable.
+func (pb *ParallelDawgBuilder) UseValueTable() {
+	pb.valueTable = true
+}
+
+// Moves values of keys which are not being built yet into a value table.
+func (pb *ParallelDawgBuilder) moveValuesToTable() {
+	pb.values = []int64{}
+	pb.valueIds = map[int64]valueType{}
+	if len(pb.shards) == 0 {
+		return
+	}
+
+	var shard *dawgShard = pb.shards[len(pb.shards)-1]
+	for i, value := range shard.values {
+		shard.values[i] = pb.valueId(int64(value))
+	}
+	shard.hasValueIds = true
+}
+
+func (pb *ParallelDawgBuilder) insertKeyValue(key []ucharType, length sizeType, value valueType) error {
+	key = key[:length]
+	if order := bytes.Compare(key, pb.lastKey); order < 0 {
+		return ErrKeyOrder
+	} else if order == 0 && len(pb.shards) != 0 {
+		if err := pb.mergeDuplicate(value); err != nil {
+			return err
+		}
+		pb.countKey()
+		return nil
+	}
+
+	// Starts the next shard if the last one is large enough, or if the key
+	// shares fewer labels with its first key than the previous shard does.
+	var prefixLength sizeType = 0
+	for prefixLength < length && prefixLength < len(pb.lastKey) && key[prefixLength] == pb.lastKey[prefixLength] {
+		prefixLength++
+	}
+	if len(pb.shards) == 0 {
+		pb.shards = append(pb.shards, &dawgShard{hasValueIds: pb.values != nil})
+	} else if shard := pb.shards[len(pb.shards)-1]; prefixLength < shard.prevLength || len(shard.keys) >= pb.shardSize {
+		shard.nextLength = prefixLength
+		pb.startShard()
+		pb.shards = append(pb.shards, &dawgShard{prevLength: prefixLength, hasValueIds: pb.values != nil})
+	}
+	pb.shards[len(pb.shards)-1].insert(key, value)
+	pb.lastKey = append(pb.lastKey[:0], key...)
+	pb.countKey()
+	return nil
+}
+
+// Inserts a key with a 64-bit value in the same way as DawgBuilder does.
+func (pb *ParallelDawgBuilder) Insert(key []ucharType, value int64) error {
+	if pb.values == nil {
+		if !pb.valueTable {
+			if value < 0 || value > MaxValue {
+				return ErrValueRange
+			}
+			return pb.insertKeyValue(key, len(key), valueType(value))
+		}
+		pb.moveValuesToTable()
+	}
+	return pb.insertKeyValue(key, len(key), pb.valueId(value))
+}
+
+func (pb *ParallelDawgBuilder) InsertKeyValue64(key []ucharType, length sizeType, value int64) bool {
+	return pb.Insert(key[:length], value) == nil
+}
+
+func (pb *ParallelDawgBuilder) InsertStringValue64(key string, value int64) bool {
+	return pb.InsertKeyValue64([]ucharType(key), len(key), value)
+}
+
+func (pb *ParallelDawgBuilder) InsertKeyValue(key []ucharType, length sizeType, value valueType) bool {
+	if pb.values != nil {
+		return pb.insertKeyValue(key, length, pb.valueId(int64(value))) == nil
+	}
+	return pb.insertKeyValue(key, length, value) == nil
+}
+
+func (pb *ParallelDawgBuilder) InsertStringValue(key string, value valueType) bool {
+	return pb.InsertKeyValue([]ucharType(key), len(key), value)
+}
+
+func (pb *ParallelDawgBuilder) InsertString(key string) bool {
+	return pb.InsertKeyValue([]ucharType(key), len(key), 0)
+}
+
+// Finishes building a dawg. Returns false if keys of some shard could not be
+// inserted.
+func (pb *ParallelDawgBuilder) Finish(dawg *Dawg) bool {
+	pb.startShard()
+	pb.group.Wait()
+
+	var ok bool = true
+	for _, shard := range pb.shards {
+		ok = ok && shard.ok
+	}
+	if ok {
+		db := NewDawgBuilder()
+		db.observer = pb.observer
+		db.numOfKeys = pb.numOfKeys
+		db.numOfDuplicates = pb.numOfDuplicates
+		if len(pb.shards) != 0 {
+			pb.merge(db)
+		}
+		db.values = pb.values
+		db.valueIds = pb.valueIds
+		if len(pb.shards) == 0 {
+			db.Finish(dawg)
+		} else {
+			db.finishDawg(dawg)
+		}
+	}
+
+	pb.shards = nil
+	pb.lastKey = nil
+	pb.values = nil
+	pb.valueIds = nil
+	pb.numOfKeys = 0
+	pb.numOfDuplicates = 0
+	return ok
+}
+
+// Merges built shards into a builder.
+func (pb *ParallelDawgBuilder) merge(db *DawgBuilder) {
+	var merger *dawgMerger = newDawgMerger(cap(pb.workers))
+	for _, shard := range pb.shards {
+		if pb.values != nil && !shard.hasValueIds {
+			shard.useValueIds(pb.valueId)
+		}
+		merger.addShard(shard)
+		shard.states = nil
+		shard.path = nil
+	}
+	merger.fixPath(-1)
+
+	merger.collectStates()
+	merger.mergeStates()
+	merger.writeStates(db)
+}
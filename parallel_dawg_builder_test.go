@@ -0,0 +1,142 @@
+package dawg
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestParallelDawgBuilder(t *testing.T) {
+	expected, _, lexicon := buildTestLexicon(t)
+
+	var keys []string
+	for key := range lexicon {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	builder := NewParallelDawgBuilder(4)
+	for _, key := range keys {
+		if !builder.InsertStringValue(key, lexicon[key]) {
+			t.Fatalf("Failed to insert %q", key)
+		}
+	}
+	if builder.InsertString("a") {
+		t.Errorf("Unsorted key is inserted")
+	}
+
+	dawg := NewDawg()
+	if !builder.Finish(dawg) {
+		t.Fatal("Failed to finish dawg")
+	}
+
+	compareDawgs(t, dawg, expected)
+}
+
+func compareDawgs(t *testing.T, dawg *Dawg, expected *Dawg) {
+	if dawg.Size() != expected.Size() || dawg.NumOfStates() != expected.NumOfStates() ||
+		dawg.NumOfMergedStates() != expected.NumOfMergedStates() ||
+		dawg.NumOfMergingStates() != expected.NumOfMergingStates() ||
+		dawg.NumOfMergedTransitions() != expected.NumOfMergedTransitions() {
+		t.Fatalf("Dawg differs from the one built sequentially")
+	}
+	for i := baseType(0); sizeType(i) < dawg.Size(); i++ {
+		if dawg.basePool[i] != expected.basePool[i] || dawg.Label(i) != expected.Label(i) ||
+			dawg.IsMerging(i) != expected.IsMerging(i) {
+			t.Fatalf("Unit %d differs from the one built sequentially", i)
+		}
+	}
+	if len(dawg.values) != len(expected.values) {
+		t.Fatalf("Value table differs from the one built sequentially")
+	}
+	for i, value := range dawg.values {
+		if value != expected.values[i] {
+			t.Fatalf("Value %d differs from the one built sequentially", i)
+		}
+	}
+}
+
+func TestParallelDawgBuilderShards(t *testing.T) {
+	var keys []string = append([]string{"", "а", "аб"}, generateCyrillicKeys(1<<12)...)
+	sort.Strings(keys)
+
+	for _, withValueTable := range []bool{false, true} {
+		builder := NewDawgBuilder()
+		parallelBuilder := NewParallelDawgBuilder(3)
+		parallelBuilder.shardSize = 1 << 8
+		if withValueTable {
+			builder.UseValueTable()
+			parallelBuilder.UseValueTable()
+		}
+		for i, key := range keys {
+			// Values of the first keys are moved into a value table later.
+			var value int64 = int64(i % 7)
+			if withValueTable && i >= len(keys)/2 {
+				value = int64(i%5) << 40
+			}
+			if i < len(keys)/2 {
+				builder.InsertStringValue(key, valueType(value))
+				parallelBuilder.InsertStringValue(key, valueType(value))
+			} else if builder.Insert([]ucharType(key), value) != nil || parallelBuilder.Insert([]ucharType(key), value) != nil {
+				t.Fatalf("Failed to insert %q", key)
+			}
+		}
+		if len(parallelBuilder.shards) < 100 {
+			t.Fatalf("Keys are split into %d shards", len(parallelBuilder.shards))
+		}
+
+		expected := NewDawg()
+		builder.Finish(expected)
+		dawg := NewDawg()
+		if !parallelBuilder.Finish(dawg) {
+			t.Fatal("Failed to finish dawg")
+		}
+		compareDawgs(t, dawg, expected)
+	}
+}
+
+// Generates sorted keys of Cyrillic letters, which share first bytes in UTF-8.
+func generateCyrillicKeys(numOfKeys int) []string {
+	rng := rand.New(rand.NewSource(1))
+	var letters = []rune("абвгдеёжзийклмнопрстуфхцчшщъыьэюя")
+	var seen = map[string]bool{}
+	var keys []string
+	for len(keys) < numOfKeys {
+		var word = make([]rune, 3+rng.Intn(10))
+		for i := range word {
+			word[i] = letters[rng.Intn(len(letters))]
+		}
+		if key := string(word); !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func BenchmarkDawgBuilder(b *testing.B) {
+	keys := generateCyrillicKeys(1 << 18)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		builder := NewDawgBuilder()
+		for j, key := range keys {
+			builder.InsertStringValue(key, valueType(j%100))
+		}
+		builder.Finish(NewDawg())
+	}
+}
+
+// Uses GOMAXPROCS goroutines, compare with BenchmarkDawgBuilder using
+// -cpu 1,4,8 to see how building scales.
+func BenchmarkParallelDawgBuilder(b *testing.B) {
+	keys := generateCyrillicKeys(1 << 18)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		builder := NewParallelDawgBuilder(0)
+		for j, key := range keys {
+			builder.InsertStringValue(key, valueType(j%100))
+		}
+		builder.Finish(NewDawg())
+	}
+}
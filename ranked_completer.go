@@ -1,12 +1,9 @@
 package dawg
 
-import (
-	"container/heap"
-)
-
 type RankedCompleter struct {
-	dict  *Dictionary
-	guide *RankedGuide
+	dict       *Dictionary
+	guide      *RankedGuide
+	aggregates *AggregateIndex
 
 	path         []ucharType
 	prefixLength sizeType
@@ -15,14 +12,53 @@ type RankedCompleter struct {
 	nodes          []RankedCompleterNode
 	nodeQueue      []baseType
 	candidateQueue RankedCompleterCandidateQueue
+
+	// Number of keys to be completed, or zero if it is unknown, and number of
+	// keys completed so far. They allow to prune subtrees by aggregates.
+	limit        sizeType
+	numOfResults sizeType
 }
 
+// Creates a completer in the order of a guide. Returns nil for guides of
+// CustomOrder, which need NewRankedCompleterCmp.
 func NewRankedCompleter(dict *Dictionary, guide *RankedGuide) *RankedCompleter {
+	if guide.Order() == CustomOrder {
+		return nil
+	}
+	return newRankedCompleter(dict, guide, guide.Order().Comparator())
+}
+
+// Creates a completer which skips subtrees that cannot contain any of the
+// best TopK results, judging by maximums (or minimums for ascending order) of
+// values in an aggregate index. Returns nil for guides of CustomOrder.
+func NewRankedCompleterWithAggregates(dict *Dictionary, guide *RankedGuide, aggregates *AggregateIndex) *RankedCompleter {
+	rc := NewRankedCompleter(dict, guide)
+	if rc != nil {
+		rc.aggregates = aggregates
+	}
+	return rc
+}
+
+// Creates a completer for a guide built by BuildRankedGuideCmp. The
+// comparator must be the one the guide was built with, which cannot be
+// checked, since only CustomOrder is kept with the guide. Returns nil for
+// guides of other orders, which need NewRankedCompleter.
+func NewRankedCompleterCmp(dict *Dictionary, guide *RankedGuide, valuesCmp valueComparatorFunc) *RankedCompleter {
+	if guide.size != 0 && guide.Order() != CustomOrder {
+		return nil
+	}
+	return newRankedCompleter(dict, guide, valuesCmp)
+}
+
+func newRankedCompleter(dict *Dictionary, guide *RankedGuide, valuesCmp valueComparatorFunc) *RankedCompleter {
 	return &RankedCompleter{
 		dict:  dict,
 		guide: guide,
 
 		value: -1,
+		candidateQueue: RankedCompleterCandidateQueue{
+			less: makeRankedCompleterCandidateCmp(valuesCmp),
+		},
 	}
 }
 
@@ -33,6 +69,9 @@ func (rc *RankedCompleter) Key() string {
 func (rc *RankedCompleter) Value() valueType {
 	return rc.value
 }
+func (rc *RankedCompleter) Value64() int64 {
+	return rc.dict.tableValue(rc.value)
+}
 
 func (rc *RankedCompleter) Length() sizeType {
 	return len(rc.path) - 1
@@ -45,16 +84,14 @@ func (rc *RankedCompleter) StartString(index baseType, prefix string) {
 	rc.StartStringLen(index, prefix, len(prefix))
 }
 func (rc *RankedCompleter) StartStringLen(index baseType, prefix string, length sizeType) {
-	rc.path = make([]ucharType, length)
-	for i := 0; i < length; i++ {
-		rc.path[i] = prefix[i]
-	}
+	rc.path = append(rc.path[:0], prefix[:length]...)
 	rc.prefixLength = length
 	rc.value = -1
+	rc.numOfResults = 0
 
 	rc.nodes = rc.nodes[:0]
 	rc.nodeQueue = rc.nodeQueue[:0]
-	rc.candidateQueue = rc.candidateQueue[:0]
+	rc.candidateQueue.clear()
 
 	if rc.guide.size != 0 {
 		rc.createNode(index, 0, 'X')
@@ -76,17 +113,20 @@ func (rc *RankedCompleter) Next() bool {
 		if rc.value != -1 && !rc.findSibling(&nodeIndex) {
 			continue
 		}
+		if rc.value != -1 && rc.canPrune(nodeIndex) {
+			continue
+		}
 		nodeIndex = rc.findTerminal(nodeIndex)
 		rc.enqueueCandidate(nodeIndex)
 	}
 	rc.nodeQueue = rc.nodeQueue[:0]
 
 	// Returns false if there is no candidate.
-	if len(rc.candidateQueue) == 0 {
+	if rc.candidateQueue.Len() == 0 {
 		return false
 	}
 
-	var candidate *RankedCompleterCandidate = rc.candidateQueue[0]
+	var candidate *RankedCompleterCandidate = rc.candidateQueue.top()
 
 	var nodeIndex baseType = candidate.nodeIndex
 	rc.enqueueNode(nodeIndex)
@@ -102,11 +142,62 @@ func (rc *RankedCompleter) Next() bool {
 	rc.path = append(rc.path, 0)
 
 	rc.value = candidate.value
-	heap.Pop(&rc.candidateQueue)
+	rc.candidateQueue.pop()
+	rc.numOfResults++
 
 	return true
 }
 
+// Finds up to k best completions of a given prefix. Keys include the prefix.
+// Results are appended to dst[:0] reusing buffers of its keys, so passing the
+// previous result back makes queries allocation-free once buffers have grown.
+// The search stops expanding nodes as soon as k results are found.
+func (rc *RankedCompleter) TopK(prefix string, k sizeType, dst []Result) []Result {
+	dst = dst[:0]
+	var index baseType = rc.dict.Root()
+	if k <= 0 || !rc.dict.FollowString(prefix, &index) {
+		return dst
+	}
+
+	rc.StartString(index, prefix)
+	rc.limit = k
+	for len(dst) < k && rc.Next() {
+		dst = appendResult(dst, rc.path[:rc.Length()], rc.Value64())
+	}
+	rc.limit = 0
+	return dst
+}
+
+// Checks if keys below a node cannot be among the remaining results, because
+// enough candidates are strictly better than the best value below it. Ties
+// are not pruned, so that they are broken by the candidate queue alone. Later
+// siblings of the node are not better, so they are skipped as well.
+func (rc *RankedCompleter) canPrune(nodeIndex baseType) bool {
+	var remaining sizeType = rc.limit - rc.numOfResults
+	if rc.aggregates == nil || rc.limit == 0 || rc.candidateQueue.Len() < remaining {
+		return false
+	}
+
+	var node *RankedCompleterNode = &rc.nodes[nodeIndex]
+	var best int64
+	if node.label == 0 {
+		best = rc.dict.Value64(rc.nodes[node.prevNodeIndex].dictIndex)
+	} else if rc.guide.Order() == AscendingOrder {
+		best = rc.aggregates.Min(node.dictIndex)
+	} else {
+		best = rc.aggregates.Max(node.dictIndex)
+	}
+
+	var numOfBetter sizeType = 0
+	for i := range rc.candidateQueue.candidates {
+		var value int64 = rc.dict.tableValue(rc.candidateQueue.candidates[i].value)
+		if value != best && (value > best) == (rc.guide.Order() == DescendingOrder) {
+			numOfBetter++
+		}
+	}
+	return numOfBetter >= remaining
+}
+
 // Pushes a node to queue.
 func (rc *RankedCompleter) enqueueNode(nodeIndex baseType) {
 	if rc.nodes[nodeIndex].isQueued {
@@ -119,9 +210,9 @@ func (rc *RankedCompleter) enqueueNode(nodeIndex baseType) {
 
 // Pushes a candidate to priority queue.
 func (rc *RankedCompleter) enqueueCandidate(nodeIndex baseType) {
-	heap.Push(&rc.candidateQueue, &RankedCompleterCandidate{
+	rc.candidateQueue.push(RankedCompleterCandidate{
 		nodeIndex: nodeIndex,
-		value:     dictValue(rc.dict.units[rc.nodes[nodeIndex].dictIndex]),
+		value:     rc.dict.leafValue(rc.nodes[nodeIndex].dictIndex),
 	})
 }
 
@@ -164,7 +255,7 @@ func (rc *RankedCompleter) findTerminal(nodeIndex baseType) baseType {
 
 // Follows a transition without any check.
 func (rc *RankedCompleter) followWithoutCheck(index baseType, label ucharType) baseType {
-	return index ^ dictOffset(rc.dict.units[index]) ^ baseType(label)
+	return index ^ rc.dict.offset(index) ^ baseType(label)
 }
 
 // Creates a node
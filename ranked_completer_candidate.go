@@ -5,7 +5,12 @@ type RankedCompleterCandidate struct {
 	value     valueType
 }
 
-type RankedCompleterCandidateQueue []*RankedCompleterCandidate
+// A binary heap of candidates, the best candidate is on top. Candidates are
+// stored by value, so pushing and popping them does not allocate.
+type RankedCompleterCandidateQueue struct {
+	candidates []RankedCompleterCandidate
+	less       func(lhs *RankedCompleterCandidate, rhs *RankedCompleterCandidate) bool
+}
 
 func makeRankedCompleterCandidateCmp(valuesCmp valueComparatorFunc) func(lhs *RankedCompleterCandidate, rhs *RankedCompleterCandidate) bool {
 	return func(lhs *RankedCompleterCandidate, rhs *RankedCompleterCandidate) bool {
@@ -16,31 +21,58 @@ func makeRankedCompleterCandidateCmp(valuesCmp valueComparatorFunc) func(lhs *Ra
 	}
 }
 
-func (pq RankedCompleterCandidateQueue) Len() int {
-	return len(pq)
+func (pq *RankedCompleterCandidateQueue) Len() int {
+	return len(pq.candidates)
 }
 
-// TODO: this does not allow custom comparator
-func (pq RankedCompleterCandidateQueue) Less(i, j int) bool {
-	if pq[i].value != pq[j].value {
-		return pq[i].value > pq[j].value
-	}
-	return pq[i].nodeIndex > pq[j].nodeIndex
+func (pq *RankedCompleterCandidateQueue) clear() {
+	pq.candidates = pq.candidates[:0]
+}
+
+// The best candidate must be popped first, so arguments are swapped.
+func (pq *RankedCompleterCandidateQueue) better(i, j int) bool {
+	return pq.less(&pq.candidates[j], &pq.candidates[i])
 }
 
-func (pq RankedCompleterCandidateQueue) Swap(i, j int) {
-	pq[i], pq[j] = pq[j], pq[i]
+// Gets the best candidate.
+func (pq *RankedCompleterCandidateQueue) top() *RankedCompleterCandidate {
+	return &pq.candidates[0]
 }
 
-func (pq *RankedCompleterCandidateQueue) Push(x interface{}) {
-	*pq = append(*pq, x.(*RankedCompleterCandidate))
+// Pushes a candidate.
+func (pq *RankedCompleterCandidateQueue) push(candidate RankedCompleterCandidate) {
+	pq.candidates = append(pq.candidates, candidate)
+	var i int = len(pq.candidates) - 1
+	for i > 0 {
+		var parent int = (i - 1) / 2
+		if !pq.better(i, parent) {
+			break
+		}
+		pq.candidates[i], pq.candidates[parent] = pq.candidates[parent], pq.candidates[i]
+		i = parent
+	}
 }
 
-func (pq *RankedCompleterCandidateQueue) Pop() interface{} {
-	old := *pq
-	n := len(old)
-	item := old[n-1]
-	old[n-1] = nil
-	*pq = old[0 : n-1]
-	return item
+// Removes the best candidate.
+func (pq *RankedCompleterCandidateQueue) pop() {
+	var last int = len(pq.candidates) - 1
+	pq.candidates[0] = pq.candidates[last]
+	pq.candidates = pq.candidates[:last]
+
+	var i int = 0
+	for {
+		var best int = i
+		var left int = 2*i + 1
+		if left < last && pq.better(left, best) {
+			best = left
+		}
+		if left+1 < last && pq.better(left+1, best) {
+			best = left + 1
+		}
+		if best == i {
+			break
+		}
+		pq.candidates[i], pq.candidates[best] = pq.candidates[best], pq.candidates[i]
+		i = best
+	}
 }
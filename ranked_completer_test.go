@@ -0,0 +1,228 @@
+package dawg
+
+import (
+	"bufio"
+	"bytes"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Builds a dawg and a dictionary from a tab separated lexicon.
+func buildTestLexicon(t *testing.T) (*Dawg, *Dictionary, map[string]valueType) {
+	file, err := os.Open("test/lexicon.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	lexicon := map[string]valueType{}
+	builder := NewDawgBuilder()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parts := strings.Split(scanner.Text(), "\t")
+		value, err := strconv.Atoi(parts[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !builder.InsertStringValue(parts[0], valueType(value)) {
+			t.Fatalf("Failed to insert %q", parts[0])
+		}
+		lexicon[parts[0]] = valueType(value)
+	}
+
+	dawg := NewDawg()
+	builder.Finish(dawg)
+	dict := dawg.Build()
+	if dict == nil {
+		t.Fatal("Failed to build dictionary")
+	}
+	return dawg, dict, lexicon
+}
+
+// Builds a dictionary of the test lexicon with an extra empty key.
+func buildTestLexiconWithEmptyKey(t *testing.T) (*Dawg, *Dictionary, map[string]valueType) {
+	_, _, lexicon := buildTestLexicon(t)
+	lexicon[""] = 5
+
+	builder := NewDawgBuilder()
+	for _, key := range sortedTestKeys(lexicon) {
+		if !builder.InsertStringValue(key, lexicon[key]) {
+			t.Fatalf("Failed to insert %q", key)
+		}
+	}
+	dawg := NewDawg()
+	builder.Finish(dawg)
+	dict := dawg.Build()
+	if dict == nil {
+		t.Fatal("Failed to build dictionary")
+	}
+	return dawg, dict, lexicon
+}
+
+func TestRankedCompleterOrder(t *testing.T) {
+	dawg, dict, lexicon := buildTestLexicon(t)
+
+	for _, order := range []RankOrder{DescendingOrder, AscendingOrder} {
+		t.Run(order.String(), func(t *testing.T) {
+			guide := BuildRankedGuideOrder(dawg, dict, order)
+			if guide.Order() != order {
+				t.Fatalf("Guide order is %v", guide.Order())
+			}
+
+			completer := NewRankedCompleter(dict, guide)
+			completer.Start(dict.Root())
+			count := 0
+			var prev valueType = -1
+			for completer.Next() {
+				key := completer.Key()[:completer.Length()]
+				if lexicon[key] != completer.Value() {
+					t.Errorf("Key %q has value %d, expected %d", key, completer.Value(), lexicon[key])
+				}
+				if prev != -1 && order.Comparator()(prev, completer.Value()) {
+					t.Errorf("Key %q with value %d follows value %d", key, completer.Value(), prev)
+				}
+				prev = completer.Value()
+				count++
+			}
+			if count != len(lexicon) {
+				t.Errorf("Completed %d keys, expected %d", count, len(lexicon))
+			}
+
+			var buf bytes.Buffer
+			if !guide.Write(&buf) {
+				t.Fatal("Failed to write guide")
+			}
+			if ReadRankedGuideOrder(bytes.NewReader(buf.Bytes()), 1-order) != nil {
+				t.Errorf("Order mismatch is not detected on load")
+			}
+			if ReadRankedGuideOrder(bytes.NewReader(buf.Bytes()), order) == nil {
+				t.Errorf("Failed to load guide")
+			}
+
+			if NewRankedCompleterCmp(dict, guide, (1-order).Comparator()) != nil {
+				t.Errorf("Comparator is accepted for a guide of %v order", order)
+			}
+		})
+	}
+}
+
+func TestRankedCompleterCustomOrder(t *testing.T) {
+	dawg, dict, lexicon := buildTestLexicon(t)
+
+	// Even values come first, then larger values.
+	var valuesCmp = func(lhs valueType, rhs valueType) bool {
+		if lhs%2 != rhs%2 {
+			return lhs%2 == 1
+		}
+		return lhs < rhs
+	}
+	guide := BuildRankedGuideCmp(dawg, dict, valuesCmp)
+	if guide.Order() != CustomOrder || guide.Validate(dict) != nil {
+		t.Fatalf("Guide order is %v", guide.Order())
+	}
+	var buf bytes.Buffer
+	if !guide.Write(&buf) || ReadRankedGuideOrder(&buf, CustomOrder) == nil {
+		t.Fatalf("Failed to load guide")
+	}
+	if NewRankedCompleter(dict, guide) != nil || NewRankedCompleterWithAggregates(dict, guide, nil) != nil {
+		t.Errorf("Guide of custom order is completed without its comparator")
+	}
+
+	completer := NewRankedCompleterCmp(dict, guide, valuesCmp)
+	completer.Start(dict.Root())
+	count := 0
+	var prev valueType = -1
+	for completer.Next() {
+		if prev != -1 && valuesCmp(prev, completer.Value()) {
+			t.Errorf("Key %q with value %d follows value %d", completer.Key(), completer.Value(), prev)
+		}
+		prev = completer.Value()
+		count++
+	}
+	if count != len(lexicon) {
+		t.Errorf("Completed %d keys, expected %d", count, len(lexicon))
+	}
+}
+
+func TestRankedCompleterTopK(t *testing.T) {
+	dawg, dict, _ := buildTestLexicon(t)
+	guide := BuildRankedGuide(dawg, dict)
+	completer := NewRankedCompleter(dict, guide)
+
+	for _, prefix := range []string{"", "a", "bin", "ca", "x"} {
+		var index baseType = dict.Root()
+		var expected []string
+		if dict.FollowString(prefix, &index) {
+			completer.StartString(index, prefix)
+			for len(expected) < 3 && completer.Next() {
+				expected = append(expected, completer.Key()[:completer.Length()])
+			}
+		}
+
+		results := completer.TopK(prefix, 3, nil)
+		if len(results) != len(expected) {
+			t.Fatalf("TopK(%q) returned %d results, expected %d", prefix, len(results), len(expected))
+		}
+		for i := range results {
+			if string(results[i].Key) != expected[i] {
+				t.Errorf("TopK(%q)[%d] = %q, expected %q", prefix, i, results[i].Key, expected[i])
+			}
+		}
+	}
+
+	results := completer.TopK("", 5, nil)
+	allocs := testing.AllocsPerRun(100, func() {
+		results = completer.TopK("", 5, results)
+		results = completer.TopK("bin", 5, results)
+	})
+	if allocs != 0 {
+		t.Errorf("TopK allocates %v times per run", allocs)
+	}
+}
+
+func TestRankedCompleterPruning(t *testing.T) {
+	// Few distinct values make many ties, which pruning must break as the
+	// candidate queue does.
+	for _, numOfValues := range []int{1000, 3} {
+		rng := rand.New(rand.NewSource(1))
+		builder := NewDawgBuilder()
+		for i := 0; i < 8*8*8; i++ {
+			key := string([]byte{'a' + byte(i/64), 'a' + byte(i/8%8), 'a' + byte(i%8)})
+			builder.InsertStringValue(key, valueType(rng.Intn(numOfValues)))
+		}
+		dawg := NewDawg()
+		builder.Finish(dawg)
+		dict := dawg.Build()
+
+		for _, order := range []RankOrder{DescendingOrder, AscendingOrder} {
+			guide := BuildRankedGuideOrder(dawg, dict, order)
+			_, aggregates := BuildAggregateIndex(dict, guide)
+			completer := NewRankedCompleter(dict, guide)
+			pruning := NewRankedCompleterWithAggregates(dict, guide, aggregates)
+
+			var numOfNodes, numOfPrunedNodes int
+			for _, prefix := range []string{"", "c", "de"} {
+				for _, k := range []sizeType{1, 3, 10, 100} {
+					expected := completer.TopK(prefix, k, nil)
+					results := pruning.TopK(prefix, k, nil)
+					numOfNodes += len(completer.nodes)
+					numOfPrunedNodes += len(pruning.nodes)
+					if len(results) != len(expected) {
+						t.Fatalf("%d values, %v: TopK(%q, %d) returned %d results, expected %d", numOfValues, order, prefix, k, len(results), len(expected))
+					}
+					for i := range results {
+						if string(results[i].Key) != string(expected[i].Key) || results[i].Value != expected[i].Value {
+							t.Errorf("%d values, %v: TopK(%q, %d)[%d] = %q, expected %q", numOfValues, order, prefix, k, i, results[i].Key, expected[i].Key)
+						}
+					}
+				}
+			}
+			if numOfPrunedNodes >= numOfNodes {
+				t.Errorf("%d values, %v: pruning visits %d nodes instead of %d", numOfValues, order, numOfPrunedNodes, numOfNodes)
+			}
+		}
+	}
+}
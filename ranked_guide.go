@@ -2,9 +2,49 @@ package dawg
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
 )
 
+// Order in which a ranked guide lists values.
+type RankOrder ucharType
+
+const (
+	// Larger values come first.
+	DescendingOrder RankOrder = 0
+	// Smaller values come first.
+	AscendingOrder RankOrder = 1
+	// Values are ranked by a comparator given to BuildRankedGuideCmp, which
+	// must be given to NewRankedCompleterCmp as well.
+	CustomOrder RankOrder = 2
+)
+
+func (order RankOrder) String() string {
+	switch order {
+	case AscendingOrder:
+		return "ascending"
+	case CustomOrder:
+		return "custom"
+	}
+	return "descending"
+}
+
+// Returns a comparator which ranks values in a given order, or nil for
+// CustomOrder.
+func (order RankOrder) Comparator() valueComparatorFunc {
+	if order == CustomOrder {
+		return nil
+	}
+	if order == AscendingOrder {
+		return func(lhs valueType, rhs valueType) bool {
+			return lhs > rhs
+		}
+	}
+	return func(lhs valueType, rhs valueType) bool {
+		return lhs < rhs
+	}
+}
+
 type RankedGuide struct {
 	units []RankedGuideUnit
 	size  sizeType
@@ -43,6 +83,70 @@ func (rg *RankedGuide) Sibling(index baseType) ucharType {
 	return rg.units[index].Sibling
 }
 
+// The order of values. It is kept in the sibling label of the root, which
+// is never used otherwise.
+func (rg *RankedGuide) Order() RankOrder {
+	if rg.size == 0 {
+		return DescendingOrder
+	}
+	return RankOrder(rg.units[rg.Root()].Sibling)
+}
+
+// Checks that the guide lists every transition of a dictionary exactly once,
+// a terminal included. Returns the first problem found.
+func (rg *RankedGuide) Validate(dict *Dictionary) error {
+	units, err := dict.reachableUnits()
+	if err != nil {
+		return err
+	}
+	if rg.size != dict.size && !(rg.size == 0 && dict.isEmpty()) {
+		return fmt.Errorf("guide has %d units, dictionary has %d", rg.size, dict.size)
+	}
+	if rg.size == 0 {
+		return nil
+	}
+	if rg.Order() > CustomOrder {
+		return fmt.Errorf("guide has unknown order %d", rg.Order())
+	}
+
+	var listed [numOfLabels]bool
+	var labels []ucharType
+	for _, index := range units {
+		labels = dict.appendChildLabels(index, labels[:0])
+		for i := range listed {
+			listed[i] = false
+		}
+
+		// A zero label is either the terminal or the end of the list.
+		var hasTerminal bool = dict.HasValue(index)
+		var count sizeType = 0
+		var label ucharType = rg.Child(index)
+		for {
+			if label == 0 {
+				if !hasTerminal {
+					break
+				}
+				hasTerminal = false
+			} else {
+				var childIndex baseType = index
+				if !dict.Follow(label, &childIndex) {
+					return fmt.Errorf("guide unit %d: label %d is not a transition", index, label)
+				}
+				if listed[label] {
+					return fmt.Errorf("guide unit %d: label %d is listed twice", index, label)
+				}
+				listed[label] = true
+				count++
+			}
+			label = rg.Sibling(index ^ dict.offset(index) ^ baseType(label))
+		}
+		if count != len(labels) {
+			return fmt.Errorf("guide unit %d: %d of %d labels are listed", index, count, len(labels))
+		}
+	}
+	return nil
+}
+
 func ReadRankedGuide(r io.Reader) *RankedGuide {
 	guide := NewRankedGuide()
 	if !guide.Read(r) {
@@ -51,6 +155,15 @@ func ReadRankedGuide(r io.Reader) *RankedGuide {
 	return guide
 }
 
+// Reads a guide and checks that it was built in a given order.
+func ReadRankedGuideOrder(r io.Reader, order RankOrder) *RankedGuide {
+	guide := ReadRankedGuide(r)
+	if guide == nil || (guide.size != 0 && guide.Order() != order) {
+		return nil
+	}
+	return guide
+}
+
 // Reads a dictionary from an input stream.
 func (rg *RankedGuide) Read(r io.Reader) bool {
 	var baseSize baseType
@@ -68,7 +181,7 @@ func (rg *RankedGuide) Read(r io.Reader) bool {
 
 	rg.units = unitsBuf
 	rg.size = len(unitsBuf)
-	return true
+	return rg.size == 0 || rg.Order() <= CustomOrder
 }
 
 // Writes a dictionary to an output stream.
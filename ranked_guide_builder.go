@@ -10,40 +10,78 @@ type RankedGuideBuilder struct {
 	units        []RankedGuideUnit
 	links        []RankedGuideLink
 	isFixedTable []ucharType
+	observer     BuildObserver
 }
 
-func BuildRankedGuideCmp(dawg *Dawg, dict *Dictionary, valuesCmp valueComparatorFunc) *RankedGuide {
-	var builder *RankedGuideBuilder = &RankedGuideBuilder{
+func NewRankedGuideBuilder(dawg *Dawg, dict *Dictionary, guide *RankedGuide) *RankedGuideBuilder {
+	return &RankedGuideBuilder{
 		dawg:  dawg,
 		dict:  dict,
-		guide: &RankedGuide{},
+		guide: guide,
 	}
+}
+
+// Builds a guide ranked by a custom comparator. Its order is CustomOrder, so
+// completers must be created by NewRankedCompleterCmp with the same
+// comparator.
+func BuildRankedGuideCmp(dawg *Dawg, dict *Dictionary, valuesCmp valueComparatorFunc) *RankedGuide {
+	var builder *RankedGuideBuilder = NewRankedGuideBuilder(dawg, dict, &RankedGuide{})
 	if !builder.Build(valuesCmp) {
 		return nil
 	}
 	return builder.guide
 }
+func BuildRankedGuideOrder(dawg *Dawg, dict *Dictionary, order RankOrder) *RankedGuide {
+	var builder *RankedGuideBuilder = NewRankedGuideBuilder(dawg, dict, &RankedGuide{})
+	if !builder.BuildOrder(order) {
+		return nil
+	}
+	return builder.guide
+}
 func BuildRankedGuide(dawg *Dawg, dict *Dictionary) *RankedGuide {
-	return BuildRankedGuideCmp(dawg, dict, func(lhs valueType, rhs valueType) bool {
-		return lhs < rhs
-	})
+	return BuildRankedGuideOrder(dawg, dict, DescendingOrder)
 }
 
+// Sets an observer which receives progress of building.
+func (rgb *RankedGuideBuilder) SetObserver(observer BuildObserver) {
+	rgb.observer = observer
+}
+
+// Builds a guide ranked by a custom comparator, see BuildRankedGuideCmp.
 func (rgb *RankedGuideBuilder) Build(valuesCmp valueComparatorFunc) bool {
+	return rgb.build(valuesCmp, CustomOrder)
+}
+
+// Builds a guide ranked in ascending or descending order.
+func (rgb *RankedGuideBuilder) BuildOrder(order RankOrder) bool {
+	if order == CustomOrder {
+		return false
+	}
+	return rgb.build(order.Comparator(), order)
+}
+
+func (rgb *RankedGuideBuilder) build(valuesCmp valueComparatorFunc, order RankOrder) bool {
+	if rgb.observer != nil {
+		rgb.observer.OnPhaseStart(GuidePhase)
+	}
+
 	// Initializes units and flags.
 	rgb.units = make([]RankedGuideUnit, rgb.dict.size)
 	rgb.isFixedTable = make([]ucharType, rgb.dict.size/8)
 
-	if rgb.dawg.Size() <= 1 {
-		return true
-	}
+	if rgb.dawg.Size() > 1 {
+		var maxValue valueType = -1
+		if !rgb.buildIndices(rgb.dawg.Root(), rgb.dict.Root(), &maxValue, valuesCmp) {
+			return false
+		}
+		rgb.units[rgb.dict.Root()].Sibling = ucharType(order)
 
-	var maxValue valueType = -1
-	if !rgb.buildIndices(rgb.dawg.Root(), rgb.dict.Root(), &maxValue, valuesCmp) {
-		return false
+		rgb.guide.setUnits(rgb.units)
 	}
 
-	rgb.guide.setUnits(rgb.units)
+	if rgb.observer != nil {
+		rgb.observer.OnPhaseEnd(&BuildStats{Phase: GuidePhase, NumOfUnits: rgb.guide.Size()})
+	}
 	return true
 }
 
@@ -142,7 +180,7 @@ func (rgb *RankedGuideBuilder) turnLinksToUnits(dictIndex baseType, linksBegin s
 
 // Follows a transition without any check.
 func (rgb *RankedGuideBuilder) followWithoutCheck(index baseType, label ucharType) baseType {
-	return index ^ dictOffset(rgb.dict.units[index]) ^ baseType(label)
+	return index ^ rgb.dict.offset(index) ^ baseType(label)
 }
 
 func (rgb *RankedGuideBuilder) setIsFixed(index baseType) {
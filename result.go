@@ -0,0 +1,20 @@
+package dawg
+
+// A key with its value, which is read from a value table if there is one.
+type Result struct {
+	Key   []byte
+	Value int64
+}
+
+// Appends a result to a slice, reusing a key buffer left in its capacity.
+func appendResult(dst []Result, key []ucharType, value int64) []Result {
+	if len(dst) < cap(dst) {
+		dst = dst[:len(dst)+1]
+	} else {
+		dst = append(dst, Result{})
+	}
+	var result *Result = &dst[len(dst)-1]
+	result.Key = append(result.Key[:0], key...)
+	result.Value = value
+	return dst
+}
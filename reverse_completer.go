@@ -0,0 +1,119 @@
+package dawg
+
+// Number of distinct labels.
+const numOfLabels = 256
+
+// Completes keys in descending order. Children are found by probing labels
+// with Dictionary.Follow from the largest one down, so no guide is needed.
+type ReverseCompleter struct {
+	dict *Dictionary
+
+	path       []ucharType
+	indexStack []baseType
+	lastIndex  baseType
+
+	// Children with labels below this one are searched by the next call to
+	// Next(). Zero means that the node on top of the stack was returned.
+	nextLabel sizeType
+}
+
+func NewReverseCompleter(dict *Dictionary) *ReverseCompleter {
+	return &ReverseCompleter{
+		dict: dict,
+	}
+}
+
+// These member functions are available only when Next() returns true.
+func (c *ReverseCompleter) Length() sizeType {
+	return len(c.path) - 1
+}
+func (c *ReverseCompleter) Key() string {
+	return string(c.path)
+}
+func (c *ReverseCompleter) Value() valueType {
+	return c.dict.Value(c.lastIndex)
+}
+func (c *ReverseCompleter) Value64() int64 {
+	return c.dict.Value64(c.lastIndex)
+}
+
+// Starts completing keys from given index and prefix.
+func (c *ReverseCompleter) Start(index baseType) {
+	c.StartStringLen(index, "", 0)
+}
+func (c *ReverseCompleter) StartString(index baseType, prefix string) {
+	c.StartStringLen(index, prefix, len(prefix))
+}
+func (c *ReverseCompleter) StartStringLen(index baseType, prefix string, length sizeType) {
+	c.path = append(c.path[:0], prefix[:length]...)
+	c.path = append(c.path, 0)
+	c.indexStack = append(c.indexStack[:0], index)
+	c.nextLabel = numOfLabels
+}
+
+// Gets the previous key.
+func (c *ReverseCompleter) Next() bool {
+	if len(c.indexStack) == 0 {
+		return false
+	}
+
+	var label sizeType = c.nextLabel
+	if label == 0 {
+		// Keys preceding the returned one are found via its parent.
+		if !c.moveToParent(&label) {
+			return false
+		}
+	}
+	c.nextLabel = 0
+	return c.findTerminal(label)
+}
+
+// Finds the last key preceding children of the current node with labels
+// starting from a given one.
+func (c *ReverseCompleter) findTerminal(label sizeType) bool {
+	for {
+		var index baseType = c.indexStack[len(c.indexStack)-1]
+		if c.findChild(&index, &label) {
+			c.path[len(c.path)-1] = ucharType(label)
+			c.path = append(c.path, 0)
+			c.indexStack = append(c.indexStack, index)
+			label = numOfLabels
+			continue
+		}
+
+		// A node precedes all of its descendants.
+		if c.dict.HasValue(index) {
+			c.lastIndex = index
+			return true
+		}
+		if !c.moveToParent(&label) {
+			return false
+		}
+	}
+}
+
+// Finds the child with the largest label below a given one.
+func (c *ReverseCompleter) findChild(index *baseType, label *sizeType) bool {
+	for childLabel := *label - 1; childLabel > 0; childLabel-- {
+		var childIndex baseType = *index
+		if c.dict.Follow(ucharType(childLabel), &childIndex) {
+			*index = childIndex
+			*label = childLabel
+			return true
+		}
+	}
+	return false
+}
+
+// Moves to the previous node and gets the label leading to the left one.
+func (c *ReverseCompleter) moveToParent(label *sizeType) bool {
+	if len(c.indexStack) == 1 {
+		c.indexStack = c.indexStack[:0]
+		return false
+	}
+	*label = sizeType(c.path[len(c.path)-2])
+	c.path = c.path[:len(c.path)-1]
+	c.path[len(c.path)-1] = 0
+	c.indexStack = c.indexStack[:len(c.indexStack)-1]
+	return true
+}
@@ -305,6 +305,17 @@ func UtfcEncode(str string) []byte {
 	return sharedBuffer.buf
 }
 
+// UtfcAppend appends UTF-C bytes of a string to a byte array and returns it.
+// Unlike UtfcEncode, it uses no shared state and is safe for concurrent use
+func UtfcAppend(buf []byte, str string) []byte {
+	st := NewUtfcState()
+	bb := &byteBuffer{buf: buf}
+	for _, ch := range str {
+		st.Follow(ch, bb)
+	}
+	return bb.buf
+}
+
 // UtfcDecode converts UTF-C byte array to a string
 func UtfcDecode(buf []byte) string {
 	offs := 0
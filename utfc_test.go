@@ -131,3 +131,13 @@ func TestUtfcZeros(t *testing.T) {
 	}
 
 }
+
+func TestUtfcAppend(t *testing.T) {
+	var prefix []byte = []byte("prefix")
+	for _, test := range append(testStrings, testZeroStrings...) {
+		utfc := UtfcAppend(prefix[:len(prefix):len(prefix)], test)
+		if string(utfc[:len(prefix)]) != "prefix" || string(utfc[len(prefix):]) != string(UtfcEncode(test)) {
+			t.Errorf("String %q appended as %v", test, hexString(utfc))
+		}
+	}
+}